@@ -11,7 +11,7 @@ import (
 
 var DB *sql.DB
 
-func Connect() {
+func ConnString() string {
 	host := os.Getenv("DB_HOST")
 	port := os.Getenv("DB_PORT")
 	user := os.Getenv("DB_USER")
@@ -19,13 +19,15 @@ func Connect() {
 	dbname := os.Getenv("DB_NAME")
 	sslmode := os.Getenv("DB_SSLMODE")
 
-	connStr := fmt.Sprintf(
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		host, port, user, password, dbname, sslmode,
 	)
+}
 
+func Connect() {
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open("postgres", ConnString())
 	if err != nil {
 		log.Fatalf("❌ sql.Open error: %v", err)
 	}
@@ -76,4 +78,308 @@ func CreateTable() {
 	}
 
 	log.Println("✅ Table 'products' ensured")
+
+	alterProductClassification := `
+	ALTER TABLE products
+		ADD COLUMN IF NOT EXISTS abc_class VARCHAR(1),
+		ADD COLUMN IF NOT EXISTS xyz_class VARCHAR(1),
+		ADD COLUMN IF NOT EXISTS classified_at TIMESTAMP`
+
+	_, err = DB.Exec(alterProductClassification)
+	if err != nil {
+		log.Fatalf("❌ Failed to add classification columns to products: %v", err)
+	}
+
+	alterProductSoftDelete := `
+	ALTER TABLE products
+		ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+		ADD COLUMN IF NOT EXISTS deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at)`
+
+	_, err = DB.Exec(alterProductSoftDelete)
+	if err != nil {
+		log.Fatalf("❌ Failed to add soft delete columns to products: %v", err)
+	}
+
+	_, err = DB.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`)
+	if err != nil {
+		log.Fatalf("❌ Failed to add version column to products: %v", err)
+	}
+
+	createProductSearchIndexes := `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(category, '')), 'B')
+		) STORED;
+	CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector);
+	CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_products_category_trgm ON products USING GIN (category gin_trgm_ops)`
+
+	_, err = DB.Exec(createProductSearchIndexes)
+	if err != nil {
+		log.Fatalf("❌ Failed to create product search indexes: %v", err)
+	}
+
+	createStockMovementTable := `
+	CREATE TABLE IF NOT EXISTS stock_movements (
+		id SERIAL PRIMARY KEY,
+		product_id INTEGER NOT NULL,
+		movement_type VARCHAR(20) NOT NULL CHECK (movement_type IN ('inbound', 'outbound')),
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_stock_movements_product_created
+		ON stock_movements (product_id, created_at);
+	-- Movements outlive purged products so the reports keep their history.
+	ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_product_id_fkey`
+
+	_, err = DB.Exec(createStockMovementTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create stock_movements table: %v", err)
+	}
+
+	log.Println("✅ Table 'stock_movements' ensured")
+
+	createSavedViewTable := `
+	CREATE TABLE IF NOT EXISTS saved_views (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		filter JSONB NOT NULL DEFAULT '{}',
+		sort VARCHAR(255) NOT NULL DEFAULT '',
+		columns JSONB NOT NULL DEFAULT '[]',
+		shared BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, name)
+	)`
+
+	_, err = DB.Exec(createSavedViewTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create saved_views table: %v", err)
+	}
+
+	log.Println("✅ Table 'saved_views' ensured")
+
+	createAuditLogTable := `
+	CREATE TABLE IF NOT EXISTS audit_logs (
+		id BIGSERIAL PRIMARY KEY,
+		actor_id INTEGER,
+		action VARCHAR(50) NOT NULL,
+		entity VARCHAR(50) NOT NULL,
+		entity_id VARCHAR(100) NOT NULL,
+		before JSONB,
+		after JSONB,
+		diff JSONB NOT NULL DEFAULT '{}',
+		ip VARCHAR(64),
+		request_id VARCHAR(100),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs (actor_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs (entity, entity_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs (created_at)`
+
+	_, err = DB.Exec(createAuditLogTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create audit_logs table: %v", err)
+	}
+
+	log.Println("✅ Table 'audit_logs' ensured")
+
+	createWebhookTables := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		events TEXT[] NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event_type VARCHAR(100) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP,
+		last_status_code INTEGER,
+		last_error TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		delivered_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+		ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook
+		ON webhook_deliveries (webhook_id, created_at)`
+
+	_, err = DB.Exec(createWebhookTables)
+	if err != nil {
+		log.Fatalf("❌ Failed to create webhook tables: %v", err)
+	}
+
+	log.Println("✅ Tables 'webhooks' and 'webhook_deliveries' ensured")
+
+	_, err = DB.Exec(`CREATE SEQUENCE IF NOT EXISTS event_id_seq`)
+	if err != nil {
+		log.Fatalf("❌ Failed to create event ID sequence: %v", err)
+	}
+
+	_, err = DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0`)
+	if err != nil {
+		log.Fatalf("❌ Failed to add token_version column to users: %v", err)
+	}
+
+	_, err = DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE`)
+	if err != nil {
+		log.Fatalf("❌ Failed to add disabled column to users: %v", err)
+	}
+
+	_, err = DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS name VARCHAR(255) NOT NULL DEFAULT ''`)
+	if err != nil {
+		log.Fatalf("❌ Failed to add name column to users: %v", err)
+	}
+
+	alterUserLockout := `
+	ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP`
+
+	_, err = DB.Exec(alterUserLockout)
+	if err != nil {
+		log.Fatalf("❌ Failed to add lockout columns to users: %v", err)
+	}
+
+	createPasswordResetTable := `
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens (user_id)`
+
+	_, err = DB.Exec(createPasswordResetTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create password_reset_tokens table: %v", err)
+	}
+
+	log.Println("✅ Table 'password_reset_tokens' ensured")
+
+	createInvitationTable := `
+	CREATE TABLE IF NOT EXISTS invitations (
+		id SERIAL PRIMARY KEY,
+		email VARCHAR(255) NOT NULL,
+		role VARCHAR(50) NOT NULL DEFAULT 'staff',
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		expires_at TIMESTAMP NOT NULL,
+		accepted_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email)`
+
+	_, err = DB.Exec(createInvitationTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create invitations table: %v", err)
+	}
+
+	log.Println("✅ Table 'invitations' ensured")
+
+	createMFATables := `
+	ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS totp_secret TEXT,
+		ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		ADD COLUMN IF NOT EXISTS totp_last_counter BIGINT NOT NULL DEFAULT 0;
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, code_hash)
+	)`
+
+	_, err = DB.Exec(createMFATables)
+	if err != nil {
+		log.Fatalf("❌ Failed to create MFA tables: %v", err)
+	}
+
+	log.Println("✅ Table 'mfa_recovery_codes' ensured")
+
+	createAPIKeyTable := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		prefix VARCHAR(32) UNIQUE NOT NULL,
+		key_hash VARCHAR(64) NOT NULL,
+		permissions TEXT[] NOT NULL,
+		rate_limit INTEGER,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		expires_at TIMESTAMP,
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS api_key_id INTEGER`
+
+	_, err = DB.Exec(createAPIKeyTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create api_keys table: %v", err)
+	}
+
+	log.Println("✅ Table 'api_keys' ensured")
+
+	_, err = DB.Exec(`ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(512) UNIQUE`)
+	if err != nil {
+		log.Fatalf("❌ Failed to add oidc_subject column to users: %v", err)
+	}
+
+	createSigningKeyTable := `
+	CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+		kid VARCHAR(64) PRIMARY KEY,
+		algorithm VARCHAR(16) NOT NULL,
+		private_key TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		activates_at TIMESTAMP,
+		retired_at TIMESTAMP,
+		expires_at TIMESTAMP
+	);
+	ALTER TABLE jwt_signing_keys ADD COLUMN IF NOT EXISTS activates_at TIMESTAMP`
+
+	_, err = DB.Exec(createSigningKeyTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create jwt_signing_keys table: %v", err)
+	}
+
+	log.Println("✅ Table 'jwt_signing_keys' ensured")
+
+	createSessionTable := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id VARCHAR(32) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		method VARCHAR(20) NOT NULL,
+		token_version INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id, last_seen_at)`
+
+	_, err = DB.Exec(createSessionTable)
+	if err != nil {
+		log.Fatalf("❌ Failed to create sessions table: %v", err)
+	}
+
+	log.Println("✅ Table 'sessions' ensured")
 }
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ProductCreated = "product.created"
+	ProductUpdated = "product.updated"
+	ProductDeleted = "product.deleted"
+	StockChanged   = "stock.changed"
+	StockLow       = "stock.low"
+)
+
+var Types = []string{ProductCreated, ProductUpdated, ProductDeleted, StockChanged, StockLow}
+
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	ProductID int64     `json:"productId,omitempty"`
+	Category  string    `json:"category,omitempty"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Remote is set on events relayed from another instance.
+	Remote bool `json:"-"`
+}
+
+type Handler func(Event)
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+)
+
+func IsValidType(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func Subscribe(handler Handler) {
+	mu.Lock()
+	handlers = append(handlers, handler)
+	mu.Unlock()
+}
+
+// Publish hands the event to every subscriber in registration order.
+// Subscribers run on the caller's goroutine and should return quickly.
+func Publish(event Event) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event = buffer.append(event)
+
+	mu.RLock()
+	subscribers := handlers
+	mu.RUnlock()
+
+	for _, handler := range subscribers {
+		handler(event)
+	}
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"sync"
+)
+
+const streamBufferSize = 64
+
+type ring struct {
+	mu     sync.Mutex
+	nextID int64
+	events []Event
+	start  int
+	size   int
+}
+
+var buffer = newRing(1000)
+
+func newRing(capacity int) *ring {
+	return &ring{nextID: 1, events: make([]Event, capacity)}
+}
+
+// SetBufferSize replaces the replay buffer. It is meant to be called once at
+// startup, before any events are published.
+func SetBufferSize(capacity int) {
+	if capacity > 0 {
+		buffer = newRing(capacity)
+	}
+}
+
+// append keeps the event for replay and fans it out to open streams. Events
+// normally carry a cluster-wide ID so Last-Event-ID means the same on every
+// instance; only events published without one are numbered locally.
+func (r *ring) append(event Event) Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.ID == 0 {
+		event.ID = r.nextID
+	}
+	if event.ID >= r.nextID {
+		r.nextID = event.ID + 1
+	}
+
+	if r.size < len(r.events) {
+		r.events[(r.start+r.size)%len(r.events)] = event
+		r.size++
+	} else {
+		r.events[r.start] = event
+		r.start = (r.start + 1) % len(r.events)
+	}
+
+	streamsMu.Lock()
+	for s := range streams {
+		if !s.filter(event) {
+			continue
+		}
+		select {
+		case s.C <- event:
+		default:
+			// The client is not keeping up; drop it so it reconnects and
+			// resumes from its Last-Event-ID.
+			delete(streams, s)
+			close(s.C)
+		}
+	}
+	streamsMu.Unlock()
+
+	return event
+}
+
+// since returns buffered events after lastID, and false if events after
+// lastID may be missing from the buffer, either because they were evicted or
+// because they happened before this instance started.
+func (r *ring) since(lastID int64, filter func(Event) bool) ([]Event, bool) {
+	var backlog []Event
+	complete := true
+
+	// Events from other instances can arrive slightly out of ID order, so
+	// look for the oldest one rather than the first in the buffer.
+	if r.size > 0 {
+		oldest := r.events[r.start].ID
+		for i := 1; i < r.size; i++ {
+			oldest = min(oldest, r.events[(r.start+i)%len(r.events)].ID)
+		}
+		if lastID < oldest-1 {
+			complete = false
+		}
+	}
+	// An ID we have not seen yet means our buffer is behind the client's.
+	if lastID >= r.nextID {
+		complete = false
+	}
+
+	for i := 0; i < r.size; i++ {
+		event := r.events[(r.start+i)%len(r.events)]
+		if event.ID > lastID && filter(event) {
+			backlog = append(backlog, event)
+		}
+	}
+	return backlog, complete
+}
+
+type Stream struct {
+	C      chan Event
+	filter func(Event) bool
+}
+
+var (
+	streamsMu sync.Mutex
+	streams   = make(map[*Stream]struct{})
+)
+
+// OpenStream registers a live stream. When lastID is positive it also
+// returns the buffered events the client missed and whether that backlog is
+// complete.
+func OpenStream(lastID int64, filter func(Event) bool) (*Stream, []Event, bool) {
+	if filter == nil {
+		filter = func(Event) bool { return true }
+	}
+	s := &Stream{C: make(chan Event, streamBufferSize), filter: filter}
+
+	// Holding the buffer lock while registering guarantees no event falls
+	// between the backlog and the live stream.
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	var backlog []Event
+	complete := true
+	if lastID > 0 {
+		backlog, complete = buffer.since(lastID, filter)
+	}
+
+	streamsMu.Lock()
+	streams[s] = struct{}{}
+	streamsMu.Unlock()
+
+	return s, backlog, complete
+}
+
+func (s *Stream) Close() {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+
+	if _, ok := streams[s]; ok {
+		delete(streams, s)
+		close(s.C)
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import "testing"
+
+func all(Event) bool { return true }
+
+func ids(events []Event) []int64 {
+	var out []int64
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestRingKeepsClusterIDs(t *testing.T) {
+	r := newRing(4)
+	for _, id := range []int64{10, 12, 11} {
+		if got := r.append(Event{ID: id}).ID; got != id {
+			t.Fatalf("append(%d) renumbered event to %d", id, got)
+		}
+	}
+	if got := r.append(Event{}).ID; got != 13 {
+		t.Errorf("event without ID got %d, want 13", got)
+	}
+}
+
+func TestRingSince(t *testing.T) {
+	r := newRing(3)
+	for _, id := range []int64{5, 7, 6} {
+		r.append(Event{ID: id})
+	}
+
+	tests := []struct {
+		lastID       int64
+		wantIDs      []int64
+		wantComplete bool
+	}{
+		{lastID: 4, wantIDs: []int64{5, 7, 6}, wantComplete: true},
+		{lastID: 5, wantIDs: []int64{7, 6}, wantComplete: true},
+		{lastID: 6, wantIDs: []int64{7}, wantComplete: true},
+		{lastID: 7, wantIDs: nil, wantComplete: true},
+		// Older than anything buffered: events may have been missed.
+		{lastID: 2, wantIDs: []int64{5, 7, 6}, wantComplete: false},
+		// Ahead of this instance, e.g. it restarted or lags behind a peer.
+		{lastID: 9, wantIDs: nil, wantComplete: false},
+	}
+
+	for _, tt := range tests {
+		backlog, complete := r.since(tt.lastID, all)
+		if complete != tt.wantComplete {
+			t.Errorf("since(%d) complete = %v, want %v", tt.lastID, complete, tt.wantComplete)
+		}
+		got := ids(backlog)
+		if len(got) != len(tt.wantIDs) {
+			t.Errorf("since(%d) = %v, want %v", tt.lastID, got, tt.wantIDs)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.wantIDs[i] {
+				t.Errorf("since(%d) = %v, want %v", tt.lastID, got, tt.wantIDs)
+				break
+			}
+		}
+	}
+}
+
+func TestRingEviction(t *testing.T) {
+	r := newRing(2)
+	for _, id := range []int64{1, 2, 3} {
+		r.append(Event{ID: id})
+	}
+
+	if _, complete := r.since(1, all); !complete {
+		t.Error("since(1) should be complete, event 2 is still buffered")
+	}
+	if _, complete := r.since(0, all); complete {
+		t.Error("since(0) should be incomplete, event 1 was evicted")
+	}
+}
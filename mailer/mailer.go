@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"stock-dashboard/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var ErrNoMailer = errors.New("no mailer configured")
+
+var (
+	mu      sync.RWMutex
+	current Mailer = unconfigured{}
+)
+
+type unconfigured struct{}
+
+func (unconfigured) Send(Message) error {
+	return ErrNoMailer
+}
+
+func SetMailer(m Mailer) {
+	mu.Lock()
+	current = m
+	mu.Unlock()
+}
+
+func Send(msg Message) error {
+	mu.RLock()
+	m := current
+	mu.RUnlock()
+	return m.Send(msg)
+}
+
+// FromEnv picks the mailer named by MAILER: log, file or smtp. There is no
+// default, since the log mailer prints working reset links and must only be
+// used when asked for.
+func FromEnv() (Mailer, error) {
+	switch driver := os.Getenv("MAILER"); driver {
+	case "":
+		return nil, fmt.Errorf("MAILER must be set to log, file or smtp")
+	case "log":
+		return LogMailer{}, nil
+	case "file":
+		return FileMailer{Dir: config.GetEnv("MAILER_DIR", "mail")}, nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for the smtp mailer")
+		}
+		return SMTPMailer{
+			Host:     host,
+			Port:     config.GetEnv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     config.GetEnv("SMTP_FROM", "no-reply@localhost"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", driver)
+	}
+}
+
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("📧 To: %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to its own file, which is handy for
+// inspecting mail in local development and tests.
+type FileMailer struct {
+	Dir string
+}
+
+func (f FileMailer) Send(msg Message) error {
+	err := os.MkdirAll(f.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(content), 0o600)
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.From, msg.To, msg.Subject, msg.Body)
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{msg.To}, []byte(content))
+}
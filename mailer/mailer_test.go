@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  string
+		host    string
+		want    Mailer
+		wantErr bool
+	}{
+		{"unset", "", "", nil, true},
+		{"log", "log", "", LogMailer{}, false},
+		{"file", "file", "", FileMailer{Dir: "mail"}, false},
+		{"smtp without host", "smtp", "", nil, true},
+		{"unknown", "sendmail", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAILER", tt.driver)
+			t.Setenv("MAILER_DIR", "")
+			t.Setenv("SMTP_HOST", tt.host)
+
+			got, err := FromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FromEnv() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendWithoutMailer(t *testing.T) {
+	err := Send(Message{To: "user@example.com"})
+	if !errors.Is(err, ErrNoMailer) {
+		t.Errorf("Send() error = %v, want %v", err, ErrNoMailer)
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"errors"
+	"log"
 	"os"
 	"stock-dashboard/config"
 	"stock-dashboard/db"
+	"stock-dashboard/events"
+	"stock-dashboard/mailer"
 	"stock-dashboard/middleware"
+	"stock-dashboard/models"
 	"stock-dashboard/routes"
+	"stock-dashboard/utils"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
@@ -14,11 +22,74 @@ import (
 func main() {
 	config.LoadEnv()
 
+	if os.Getenv("MFA_ENCRYPTION_KEY") == "" {
+		log.Fatal("❌ MFA_ENCRYPTION_KEY must be set in the environment to encrypt two-factor secrets and signing keys, see .env.example")
+	}
+	if _, err := utils.HS256AcceptedUntil(); err != nil {
+		log.Fatalf("❌ Invalid JWT_ACCEPT_HS256_UNTIL: %v", err)
+	}
+
 	db.Connect()
 
-	server := gin.Default()
+	if opts := models.SigningKeyOptions(); opts.Algorithm != utils.AlgHS256 {
+		_, err := models.EnsureSigningKey(opts, false)
+		if err != nil {
+			log.Fatalf("❌ Failed to prepare JWT signing key: %v", err)
+		}
+		err = models.LoadSigningKeys()
+		if err != nil {
+			log.Fatalf("❌ Failed to load JWT signing keys: %v", err)
+		}
+		utils.SetKeyRefresher(models.LoadSigningKeys)
+		models.StartSigningKeyRotation(opts)
+	}
+
+	// Registration is invite-only by default and invitations need an
+	// admin, so a fresh install seeds its first admin from the environment.
+	if email := config.GetEnv("ADMIN_EMAIL", ""); email != "" {
+		created, err := models.BootstrapAdmin(email, os.Getenv("ADMIN_PASSWORD"))
+		if errors.Is(err, models.ErrEmailTaken) {
+			log.Printf("⚠️ ADMIN_EMAIL %s already belongs to a non-admin account, not creating an admin", email)
+		} else if err != nil {
+			log.Fatalf("❌ Failed to create the initial admin: %v", err)
+		} else if created {
+			log.Printf("✅ Created initial admin %s", email)
+		}
+	}
+
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to configure mailer: %v", err)
+	}
+	mailer.SetMailer(mail)
+
+	events.SetBufferSize(config.GetEnvInt("EVENT_BUFFER_SIZE", 1000))
+	models.StartEventListener()
+	models.StartWebhookDispatcher(time.Duration(config.GetEnvInt("WEBHOOK_DISPATCH_INTERVAL_SECONDS", 5)) * time.Second)
+
+	models.StartClassificationScheduler(
+		time.Duration(config.GetEnvInt("CLASSIFICATION_INTERVAL_HOURS", 24))*time.Hour,
+		models.ClassificationOptions{WindowDays: config.GetEnvInt("CLASSIFICATION_WINDOW_DAYS", 90)},
+	)
+
+	server := gin.New()
+
+	// Client IPs feed login throttling, audit logs and sessions, so only
+	// take X-Forwarded-For from proxies we run. TRUSTED_PROXIES is a comma
+	// separated list of IPs or CIDRs and defaults to none.
+	var trustedProxies []string
+	for _, proxy := range strings.Split(config.GetEnv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := server.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("❌ Invalid TRUSTED_PROXIES: %v", err)
+	}
 
+	server.Use(middleware.LoggerMiddleware(), gin.Recovery())
 	server.Use(middleware.CorsMiddleware())
+	server.Use(middleware.RequestIDMiddleware())
 
 	routes.RegisterRoutes(server)
 
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"stock-dashboard/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scopes := map[string]string{
+		"GET /api/products":  models.PermProductsRead,
+		"POST /api/products": models.PermProductsWrite,
+	}
+	readOnly := &models.APIKey{Permissions: []string{models.PermProductsRead}}
+
+	tests := []struct {
+		name   string
+		key    *models.APIKey
+		method string
+		path   string
+		want   int
+	}{
+		{"user token", nil, http.MethodDelete, "/api/staff", http.StatusOK},
+		{"granted", readOnly, http.MethodGet, "/api/products", http.StatusOK},
+		{"missing permission", readOnly, http.MethodPost, "/api/products", http.StatusForbidden},
+		{"unlisted route", readOnly, http.MethodDelete, "/api/staff", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.key != nil {
+					c.Set("apiKey", tt.key)
+				}
+			}, APIKeyScopes(scopes))
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			router.GET("/api/products", ok)
+			router.POST("/api/products", ok)
+			router.DELETE("/api/staff", ok)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+			}
+		})
+	}
+}
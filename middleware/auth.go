@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"stock-dashboard/config"
+	"stock-dashboard/models"
 	"stock-dashboard/utils"
 	"strings"
 
@@ -15,6 +19,11 @@ func JWTAuthMiddleware(c *gin.Context) {
 	}
 
 	auth := c.GetHeader("Authorization")
+	if apiKey := apiKeyFromRequest(c); apiKey != "" {
+		authenticateAPIKey(c, apiKey)
+		return
+	}
+
 	if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 			"message": "Unauthorized: Missing or invalid token",
@@ -23,19 +32,95 @@ func JWTAuthMiddleware(c *gin.Context) {
 	}
 
 	token := strings.TrimPrefix(auth, "Bearer ")
-	claims, err := utils.VerifyToken(token)
+	claims, err := authenticateToken(token)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "Unauthorized: Invalid token",
-		})
+		abortAuth(c, err)
 		return
 	}
+
 	c.Set("userID", claims.UserID)
 	c.Set("role", claims.Role)
+	c.Set("mfa", claims.MFA)
+	c.Set("sessionID", claims.SessionID)
 	c.Next()
 
 }
 
+type authError struct {
+	status  int
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
+func abortAuth(c *gin.Context, err error) {
+	var authErr *authError
+	if !errors.As(err, &authErr) {
+		authErr = &authError{http.StatusInternalServerError, "Could not verify token"}
+	}
+	c.AbortWithStatusJSON(authErr.status, gin.H{"message": authErr.message})
+}
+
+// authenticateToken verifies the JWT and checks that it has not been revoked
+// since it was issued.
+func authenticateToken(token string) (*utils.CustomClaims, error) {
+	claims, err := utils.VerifyToken(token)
+	if err != nil || claims.Purpose != "" {
+		return nil, &authError{http.StatusUnauthorized, "Unauthorized: Invalid token"}
+	}
+
+	version, err := models.GetTokenVersion(claims.UserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, &authError{http.StatusInternalServerError, "Could not verify token"}
+	}
+	if err != nil || version != claims.TokenVersion {
+		return nil, &authError{http.StatusUnauthorized, "Unauthorized: Token has been revoked"}
+	}
+
+	// Tokens issued before sessions were tracked carry no session ID and
+	// stay valid until they expire.
+	if claims.SessionID != "" {
+		err = models.TouchSession(claims.SessionID, claims.UserID)
+		if errors.Is(err, models.ErrSessionNotFound) {
+			return nil, &authError{http.StatusUnauthorized, "Unauthorized: Session has been signed out"}
+		}
+		if err != nil {
+			return nil, &authError{http.StatusInternalServerError, "Could not verify session"}
+		}
+	}
+
+	return claims, nil
+}
+
+// Reauthenticate checks the request's credentials again. Long-lived
+// requests such as event streams call it periodically so they end once the
+// token expires, is revoked or its session is signed out.
+func Reauthenticate(c *gin.Context) error {
+	if _, ok := c.Get("apiKey"); ok {
+		_, err := models.AuthenticateAPIKey(apiKeyFromRequest(c))
+		if errors.Is(err, models.ErrInvalidAPIKey) {
+			return &authError{http.StatusUnauthorized, "Unauthorized: Invalid API key"}
+		}
+		if err != nil {
+			return &authError{http.StatusInternalServerError, "Could not verify API key"}
+		}
+		return nil
+	}
+
+	_, err := authenticateToken(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	return err
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	apiKey := c.GetHeader("X-API-Key")
+	if auth := c.GetHeader("Authorization"); apiKey == "" && strings.HasPrefix(auth, "Bearer "+models.APIKeyPrefix) {
+		apiKey = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return apiKey
+}
+
 func AdminOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, _ := c.Get("role")
@@ -45,6 +130,13 @@ func AdminOnly() gin.HandlerFunc {
 			})
 			return
 		}
+		if config.GetEnvBool("MFA_REQUIRED_FOR_ADMINS", true) && !c.GetBool("mfa") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message":     "Forbidden: Two-factor authentication is required for admin actions",
+				"mfaRequired": true,
+			})
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sensitiveQueryParams carry credentials and are masked in the access log.
+var sensitiveQueryParams = []string{"access_token", "token", "code"}
+
+// LoggerMiddleware is gin's access log with credentials in the query string
+// redacted.
+func LoggerMiddleware() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			redactQuery(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+func redactQuery(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+
+	values, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i]
+	}
+
+	redacted := false
+	for _, name := range sensitiveQueryParams {
+		if values.Has(name) {
+			values.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return path[:i+1] + values.Encode()
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import "testing"
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/products", "/api/products"},
+		{"/api/products?limit=10", "/api/products?limit=10"},
+		{"/api/events/stream?access_token=eyJhbGc.x.y&types=stock.low", "/api/events/stream?access_token=REDACTED&types=stock.low"},
+		{"/api/auth/oidc/callback?state=abc&code=secret", "/api/auth/oidc/callback?code=REDACTED&state=abc"},
+		{"/api/events/stream?access_token=%zz", "/api/events/stream"},
+	}
+
+	for _, tt := range tests {
+		if got := redactQuery(tt.path); got != tt.want {
+			t.Errorf("redactQuery(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
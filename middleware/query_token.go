@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// TokenFromQuery lets clients that cannot set headers, such as the browser
+// EventSource API, pass the JWT as ?access_token=. Only use it on routes
+// that need it: our access log redacts the token, but proxies in front of
+// the server may still record it.
+func TokenFromQuery(c *gin.Context) {
+	if c.GetHeader("Authorization") == "" {
+		if token := c.Query("access_token"); token != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	c.Next()
+}
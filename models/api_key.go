@@ -0,0 +1,203 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix marks API keys so they can be told apart from JWTs and are
+// easy to spot in leaked-secret scans.
+const APIKeyPrefix = "sdk_"
+
+// apiKeyIDBytes random bytes, hex encoded, follow APIKeyPrefix to form a
+// key's public prefix. The secret after it is base64url and may itself
+// contain underscores, so keys are split by length rather than on "_".
+const apiKeyIDBytes = 6
+
+var apiKeyPrefixLen = len(APIKeyPrefix) + hex.EncodedLen(apiKeyIDBytes)
+
+type APIKey struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name" binding:"required,max=100"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions" binding:"required,min=1"`
+	RateLimit   *int       `json:"rateLimit" binding:"omitempty,gt=0"`
+	CreatedBy   *string    `json:"createdBy"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+const apiKeyColumns = `id, name, prefix, permissions, rate_limit, created_by::text, expires_at, last_used_at, revoked_at, created_at`
+
+func (k *APIKey) scan(row rowScanner) error {
+	return row.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.Permissions), &k.RateLimit, &k.CreatedBy,
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+}
+
+func (k *APIKey) Validate() error {
+	for _, perm := range k.Permissions {
+		allowed := false
+		for _, p := range APIKeyPermissions {
+			if p == perm {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("permission %q cannot be granted to an API key", perm)
+		}
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
+
+func (k *APIKey) HasPermission(permission string) bool {
+	for _, p := range k.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Save creates the key and returns the full secret, which is shown once
+// and only stored as a hash.
+func (k *APIKey) Save() (string, error) {
+	b := make([]byte, apiKeyIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	k.Prefix = APIKeyPrefix + hex.EncodeToString(b)
+
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	plaintext := k.Prefix + "_" + secret
+
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, permissions, rate_limit, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + apiKeyColumns
+
+	err = k.scan(db.DB.QueryRow(query, k.Name, k.Prefix, utils.HashToken(plaintext), pq.Array(k.Permissions),
+		k.RateLimit, k.CreatedBy, k.ExpiresAt, time.Now()))
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+func GetAPIKeys() ([]APIKey, error) {
+	rows, err := db.DB.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		if err := key.scan(rows); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (k *APIKey) Get() error {
+	err := k.scan(db.DB.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, k.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+func (k *APIKey) Update() error {
+	query := `
+		UPDATE api_keys SET name = $1, permissions = $2, rate_limit = $3, expires_at = $4
+		WHERE id = $5 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+
+	err := k.scan(db.DB.QueryRow(query, k.Name, pq.Array(k.Permissions), k.RateLimit, k.ExpiresAt, k.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+func (k *APIKey) Revoke() error {
+	query := `
+		UPDATE api_keys SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+
+	err := k.scan(db.DB.QueryRow(query, time.Now(), k.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// AuthenticateAPIKey looks the key up by its public prefix and compares the
+// hash of the whole key in constant time.
+func AuthenticateAPIKey(raw string) (*APIKey, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) || len(raw) <= apiKeyPrefixLen+1 || raw[apiKeyPrefixLen] != '_' {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key APIKey
+	var hash string
+	query := `SELECT ` + apiKeyColumns + `, key_hash FROM api_keys WHERE prefix = $1`
+	err := db.DB.QueryRow(query, raw[:apiKeyPrefixLen]).Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Permissions),
+		&key.RateLimit, &key.CreatedBy, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(raw)), []byte(hash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now())) {
+		return nil, ErrInvalidAPIKey
+	}
+	return &key, nil
+}
+
+// TouchAPIKey records use of the key, at most once a minute per key.
+func TouchAPIKey(id int64) error {
+	now := time.Now()
+	_, err := db.DB.Exec(`
+		UPDATE api_keys SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, now, id, now.Add(-time.Minute))
+	return err
+}
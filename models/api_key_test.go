@@ -0,0 +1,114 @@
+package models
+
+import (
+	"errors"
+	"stock-dashboard/utils"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	const prefix = APIKeyPrefix + "0123456789ab"
+	// Secrets are base64url, so they can contain underscores of their own.
+	const raw = prefix + "_se_cr_et-"
+	columns := []string{"id", "name", "prefix", "permissions", "rate_limit", "created_by", "expires_at",
+		"last_used_at", "revoked_at", "created_at", "key_hash"}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	keyRow := func(hash string, expiresAt, revokedAt *time.Time) *sqlmock.Rows {
+		return sqlmock.NewRows(columns).AddRow(1, "ci", prefix, pq.Array([]string{PermProductsRead}), nil,
+			"7", expiresAt, nil, revokedAt, past, hash)
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		rows    *sqlmock.Rows
+		wantErr error
+	}{
+		{"valid", raw, keyRow(utils.HashToken(raw), &future, nil), nil},
+		{"no expiry", raw, keyRow(utils.HashToken(raw), nil, nil), nil},
+		{"wrong secret", prefix + "_guess", keyRow(utils.HashToken(raw), nil, nil), ErrInvalidAPIKey},
+		{"unknown prefix", raw, sqlmock.NewRows(columns), ErrInvalidAPIKey},
+		{"revoked", raw, keyRow(utils.HashToken(raw), nil, &past), ErrInvalidAPIKey},
+		{"expired", raw, keyRow(utils.HashToken(raw), &past, nil), ErrInvalidAPIKey},
+		{"missing prefix", "0123456789ab_secret", nil, ErrInvalidAPIKey},
+		{"missing secret", prefix, nil, ErrInvalidAPIKey},
+		{"empty secret", prefix + "_", nil, ErrInvalidAPIKey},
+		{"short prefix", APIKeyPrefix + "0123_secret", nil, ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			if tt.rows != nil {
+				mock.ExpectQuery(`FROM api_keys WHERE prefix = \$1`).WithArgs(prefix).WillReturnRows(tt.rows)
+			}
+
+			key, err := AuthenticateAPIKey(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (key.ID != 1 || key.CreatedBy == nil || *key.CreatedBy != "7") {
+				t.Errorf("AuthenticateAPIKey() = %+v, want key 1 created by 7", key)
+			}
+		})
+	}
+}
+
+func TestAPIKeyPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		wantErr     bool
+	}{
+		{"api scopes", APIKeyPermissions, false},
+		{"read only", []string{PermProductsRead}, false},
+		{"admin permission", []string{PermProductsRead, PermStaffManage}, true},
+		{"unknown permission", []string{"products:everything"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := APIKey{Name: "ci", Permissions: tt.permissions}
+			if err := key.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	key := APIKey{Permissions: []string{PermProductsRead}}
+	if !key.HasPermission(PermProductsRead) || key.HasPermission(PermProductsWrite) {
+		t.Errorf("HasPermission() does not match %v", key.Permissions)
+	}
+}
+
+func TestSavedAPIKeyAuthenticates(t *testing.T) {
+	columns := []string{"id", "name", "prefix", "permissions", "rate_limit", "created_by", "expires_at",
+		"last_used_at", "revoked_at", "created_at"}
+
+	// Roughly half of all generated secrets contain an underscore.
+	for i := 0; i < 20; i++ {
+		mock := mockDB(t)
+		mock.ExpectQuery(`INSERT INTO api_keys`).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "ci", "", pq.Array([]string{PermProductsRead}), nil, nil, nil, nil, nil, time.Now()))
+
+		key := APIKey{Name: "ci", Permissions: []string{PermProductsRead}}
+		raw, err := key.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix, hash := raw[:apiKeyPrefixLen], utils.HashToken(raw)
+
+		mock.ExpectQuery(`FROM api_keys WHERE prefix = \$1`).WithArgs(prefix).WillReturnRows(
+			sqlmock.NewRows(append(columns, "key_hash")).AddRow(1, "ci", prefix, pq.Array([]string{PermProductsRead}),
+				nil, nil, nil, nil, nil, time.Now(), hash))
+		if _, err := AuthenticateAPIKey(raw); err != nil {
+			t.Fatalf("AuthenticateAPIKey(%q) error = %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"stock-dashboard/db"
+	"time"
+)
+
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	ActorID   *string         `json:"actorId"`
+	APIKeyID  *string         `json:"apiKeyId,omitempty"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entityId"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Diff      json.RawMessage `json:"diff"`
+	IP        string          `json:"ip"`
+	RequestID string          `json:"requestId"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type AuditFilter struct {
+	ActorID  string
+	Entity   string
+	EntityID string
+	Action   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Page     int
+}
+
+type AuditListResult struct {
+	Entries    []AuditEntry `json:"entries"`
+	Total      int          `json:"total"`
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+	TotalPages int          `json:"total_pages"`
+}
+
+type auditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+func toJSONObject(v any) (json.RawMessage, map[string]any, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil, nil, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fields map[string]any
+	if json.Unmarshal(raw, &fields) != nil {
+		fields = map[string]any{"value": v}
+	}
+	return raw, fields, nil
+}
+
+// auditDiff compares the top-level fields of two JSON objects and returns
+// the ones whose values differ.
+func auditDiff(before, after map[string]any) map[string]auditChange {
+	diff := make(map[string]auditChange)
+	for key, from := range before {
+		to, ok := after[key]
+		if !ok || !reflect.DeepEqual(from, to) {
+			diff[key] = auditChange{From: from, To: to}
+		}
+	}
+	for key, to := range after {
+		if _, ok := before[key]; !ok {
+			diff[key] = auditChange{From: nil, To: to}
+		}
+	}
+	return diff
+}
+
+func RecordAudit(actorID, apiKeyID, action, entity, entityID string, before, after any, ip, requestID string) error {
+	beforeJSON, beforeFields, err := toJSONObject(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, afterFields, err := toJSONObject(after)
+	if err != nil {
+		return err
+	}
+
+	diff, err := json.Marshal(auditDiff(beforeFields, afterFields))
+	if err != nil {
+		return err
+	}
+
+	var actor, apiKey *string
+	if actorID != "" {
+		actor = &actorID
+	}
+	if apiKeyID != "" {
+		apiKey = &apiKeyID
+	}
+
+	query := `
+		INSERT INTO audit_logs (actor_id, api_key_id, action, entity, entity_id, before, after, diff, ip, request_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err = db.DB.Exec(query, actor, apiKey, action, entity, entityID, nullableJSON(beforeJSON), nullableJSON(afterJSON),
+		diff, ip, requestID, time.Now())
+	return err
+}
+
+func nullableJSON(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	return []byte(raw)
+}
+
+func GetAuditLogs(filter AuditFilter) (*AuditListResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	var args []any
+	whereClause := ""
+
+	if filter.ActorID != "" {
+		args = append(args, filter.ActorID)
+		whereClause += fmt.Sprintf(" AND actor_id = $%d", len(args))
+	}
+	if filter.Entity != "" {
+		args = append(args, filter.Entity)
+		whereClause += fmt.Sprintf(" AND entity = $%d", len(args))
+	}
+	if filter.EntityID != "" {
+		args = append(args, filter.EntityID)
+		whereClause += fmt.Sprintf(" AND entity_id = $%d", len(args))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		whereClause += fmt.Sprintf(" AND action = $%d", len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		whereClause += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		whereClause += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	var total int
+	err := db.DB.QueryRow(`SELECT COUNT(*) FROM audit_logs WHERE 1=1`+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, actor_id::text, api_key_id::text, action, entity, entity_id, before, after, diff, ip, request_id, created_at
+		FROM audit_logs WHERE 1=1` + whereClause
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after []byte
+		var ip, requestID sql.NullString
+		err := rows.Scan(&entry.ID, &entry.ActorID, &entry.APIKeyID, &entry.Action, &entry.Entity, &entry.EntityID,
+			&before, &after, &entry.Diff, &ip, &requestID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before = before
+		entry.After = after
+		entry.IP = ip.String
+		entry.RequestID = requestID.String
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	totalPages := (total + filter.Limit - 1) / filter.Limit
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	return &AuditListResult{
+		Entries:    entries,
+		Total:      total,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		TotalPages: totalPages,
+	}, nil
+}
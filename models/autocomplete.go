@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"strings"
+	"time"
+)
+
+const (
+	SuggestionProduct  = "product"
+	SuggestionCategory = "category"
+)
+
+type Suggestion struct {
+	Type     string `json:"type"`
+	ID       int64  `json:"id,omitempty"`
+	Label    string `json:"label"`
+	Category string `json:"category,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+var autocompleteCache = utils.NewTTLCache[[]Suggestion](30*time.Second, 2000)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func InvalidateAutocompleteCache() {
+	autocompleteCache.Clear()
+}
+
+func Autocomplete(term string, types []string, limit int) ([]Suggestion, error) {
+	key := fmt.Sprintf("%s|%d|%s", strings.Join(types, ","), limit, strings.ToLower(term))
+	if suggestions, ok := autocompleteCache.Get(key); ok {
+		return suggestions, nil
+	}
+
+	prefix := likeEscaper.Replace(term) + "%"
+	suggestions := []Suggestion{}
+
+	for _, t := range types {
+		var found []Suggestion
+		var err error
+
+		switch t {
+		case SuggestionProduct:
+			found, err = autocompleteProducts(term, prefix, limit)
+		case SuggestionCategory:
+			found, err = autocompleteCategories(term, prefix, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, found...)
+	}
+
+	autocompleteCache.Set(key, suggestions)
+	return suggestions, nil
+}
+
+func autocompleteProducts(term, prefix string, limit int) ([]Suggestion, error) {
+	query := `
+		SELECT id, name, category
+		FROM products
+		WHERE deleted_at IS NULL AND (name ILIKE $2 OR $1 <% name)
+		ORDER BY name ILIKE $2 DESC, word_similarity($1, name) DESC, name
+		LIMIT $3
+	`
+
+	rows, err := db.DB.Query(query, term, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []Suggestion
+	for rows.Next() {
+		s := Suggestion{Type: SuggestionProduct}
+		err := rows.Scan(&s.ID, &s.Label, &s.Category)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	return suggestions, rows.Err()
+}
+
+func autocompleteCategories(term, prefix string, limit int) ([]Suggestion, error) {
+	query := `
+		SELECT category, COUNT(*)
+		FROM products
+		WHERE deleted_at IS NULL AND (category ILIKE $2 OR $1 <% category)
+		GROUP BY category
+		ORDER BY category ILIKE $2 DESC, word_similarity($1, category) DESC, COUNT(*) DESC
+		LIMIT $3
+	`
+
+	rows, err := db.DB.Query(query, term, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []Suggestion
+	for rows.Next() {
+		s := Suggestion{Type: SuggestionCategory}
+		err := rows.Scan(&s.Label, &s.Count)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	return suggestions, rows.Err()
+}
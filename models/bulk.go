@@ -0,0 +1,331 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"stock-dashboard/db"
+	"stock-dashboard/events"
+)
+
+const (
+	BulkAtomic     = "atomic"
+	BulkBestEffort = "best_effort"
+
+	BulkOpUpdate = "update"
+	BulkOpDelete = "delete"
+
+	BulkStatusOK         = "ok"
+	BulkStatusFailed     = "failed"
+	BulkStatusRolledBack = "rolled_back"
+	BulkStatusSkipped    = "skipped"
+
+	MaxBulkOperations = 500
+)
+
+type ProductPatch struct {
+	Name         *string  `json:"name,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+	Price        *float64 `json:"price,omitempty" binding:"omitempty,gt=0"`
+	PricePercent *float64 `json:"price_percent,omitempty"`
+	Stock        *int     `json:"stock,omitempty" binding:"omitempty,gte=0"`
+	StockDelta   *int     `json:"stock_delta,omitempty"`
+}
+
+type BulkOperation struct {
+	Op      string       `json:"op" binding:"required,oneof=update delete"`
+	ID      int64        `json:"id" binding:"required"`
+	Version int          `json:"version,omitempty"`
+	Patch   ProductPatch `json:"patch"`
+}
+
+type BulkRequest struct {
+	Mode       string          `json:"mode"`
+	Operations []BulkOperation `json:"operations" binding:"omitempty,dive"`
+	Filter     *ProductFilter  `json:"filter,omitempty"`
+	FilterOp   string          `json:"filter_op,omitempty"`
+	Patch      ProductPatch    `json:"patch"`
+	ActorID    string          `json:"-"`
+}
+
+type BulkItemResult struct {
+	Index   int    `json:"index"`
+	ID      int64  `json:"id"`
+	Op      string `json:"op"`
+	Status  string `json:"status"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	Before *Product `json:"-"`
+	After  *Product `json:"-"`
+
+	queued []events.Event
+}
+
+type BulkResult struct {
+	Mode      string           `json:"mode"`
+	Committed bool             `json:"committed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+var ErrInvalidBulkRequest = errors.New("invalid bulk request")
+
+func (p ProductPatch) isEmpty() bool {
+	return p.Name == nil && p.Category == nil && p.Price == nil &&
+		p.PricePercent == nil && p.Stock == nil && p.StockDelta == nil
+}
+
+func (p ProductPatch) validate() error {
+	if p.Price != nil && p.PricePercent != nil {
+		return fmt.Errorf("%w: price and price_percent are mutually exclusive", ErrInvalidBulkRequest)
+	}
+	if p.Stock != nil && p.StockDelta != nil {
+		return fmt.Errorf("%w: stock and stock_delta are mutually exclusive", ErrInvalidBulkRequest)
+	}
+	if p.PricePercent != nil && *p.PricePercent <= -100 {
+		return fmt.Errorf("%w: price_percent must be greater than -100", ErrInvalidBulkRequest)
+	}
+	return nil
+}
+
+// resolve turns a relative patch into absolute values for a product whose
+// current price and stock have been read under lock.
+func (p ProductPatch) resolve(id int64, version int, price float64, stock int) (*ProductUpdate, error) {
+	update := &ProductUpdate{ID: id, Version: version, Name: p.Name, Category: p.Category, Price: p.Price, Stock: p.Stock}
+
+	if p.PricePercent != nil {
+		newPrice := math.Round(price*(1+*p.PricePercent/100)*100) / 100
+		if newPrice <= 0 {
+			return nil, errors.New("resulting price must be greater than 0")
+		}
+		update.Price = &newPrice
+	}
+	if p.StockDelta != nil {
+		newStock := stock + *p.StockDelta
+		if newStock < 0 {
+			return nil, errors.New("resulting stock must not be negative")
+		}
+		update.Stock = &newStock
+	}
+
+	return update, nil
+}
+
+func (r *BulkRequest) expand(tx *sql.Tx) ([]BulkOperation, error) {
+	if r.Filter == nil {
+		if len(r.Operations) == 0 {
+			return nil, fmt.Errorf("%w: operations or filter is required", ErrInvalidBulkRequest)
+		}
+		for _, op := range r.Operations {
+			if err := op.Patch.validate(); err != nil {
+				return nil, err
+			}
+			if op.Op == BulkOpUpdate && op.Patch.isEmpty() {
+				return nil, fmt.Errorf("%w: update operation for product %d has an empty patch", ErrInvalidBulkRequest, op.ID)
+			}
+		}
+		return r.Operations, nil
+	}
+
+	if len(r.Operations) > 0 {
+		return nil, fmt.Errorf("%w: operations and filter cannot be combined", ErrInvalidBulkRequest)
+	}
+	if r.FilterOp == "" {
+		r.FilterOp = BulkOpUpdate
+	}
+	if r.FilterOp != BulkOpUpdate && r.FilterOp != BulkOpDelete {
+		return nil, fmt.Errorf("%w: filter_op must be update or delete", ErrInvalidBulkRequest)
+	}
+	if r.FilterOp == BulkOpUpdate && r.Patch.isEmpty() {
+		return nil, fmt.Errorf("%w: patch is required", ErrInvalidBulkRequest)
+	}
+	if err := r.Patch.validate(); err != nil {
+		return nil, err
+	}
+
+	filter := *r.Filter
+	filter.Trashed = false
+	filter.ExactMatch = true
+	filterClause, args := filter.whereClause()
+
+	query := `SELECT id FROM products WHERE 1=1` + filterClause + ` ORDER BY id`
+	query += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	args = append(args, MaxBulkOperations+1)
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var operations []BulkOperation
+	for rows.Next() {
+		op := BulkOperation{Op: r.FilterOp, Patch: r.Patch}
+		if err := rows.Scan(&op.ID); err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
+func lockProduct(tx *sql.Tx, id int64) (*Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+
+	var product Product
+	err := product.scan(tx.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *BulkRequest) apply(tx *sql.Tx, op BulkOperation, item *BulkItemResult) error {
+	before, err := lockProduct(tx, op.ID)
+	if err != nil {
+		return err
+	}
+	item.Before = before
+
+	switch op.Op {
+	case BulkOpDelete:
+		product := Product{ID: op.ID, Version: op.Version, DeletedBy: &r.ActorID}
+		err = product.delete(tx)
+		if err != nil {
+			return err
+		}
+	case BulkOpUpdate:
+		update, err := op.Patch.resolve(op.ID, op.Version, before.Price, before.Stock)
+		if err != nil {
+			return err
+		}
+		err = update.update(tx)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported operation %q", op.Op)
+	}
+
+	var after Product
+	err = after.scan(tx.QueryRow(`SELECT `+productColumns+` FROM products WHERE id = $1`, op.ID))
+	if err != nil {
+		return err
+	}
+	item.After = &after
+	item.Version = after.Version
+
+	changes := productChangeEvents(before.Stock, after)
+	if op.Op == BulkOpDelete {
+		changes = []events.Event{productEvent(events.ProductDeleted, after)}
+	}
+	item.queued, err = queueEvents(tx, changes...)
+	return err
+}
+
+// ExecuteBulk runs every operation in one transaction. In atomic mode the
+// first failure rolls everything back; in best-effort mode each operation
+// runs inside its own savepoint so failures only undo that item.
+func ExecuteBulk(r BulkRequest) (*BulkResult, error) {
+	if r.Mode == "" {
+		r.Mode = BulkAtomic
+	}
+	if r.Mode != BulkAtomic && r.Mode != BulkBestEffort {
+		return nil, fmt.Errorf("%w: mode must be atomic or best_effort", ErrInvalidBulkRequest)
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	operations, err := r.expand(tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) > MaxBulkOperations {
+		return nil, fmt.Errorf("%w: at most %d products can be changed at once", ErrInvalidBulkRequest, MaxBulkOperations)
+	}
+
+	result := &BulkResult{Mode: r.Mode, Results: make([]BulkItemResult, len(operations))}
+	aborted := false
+
+	for i, op := range operations {
+		item := &result.Results[i]
+		item.Index, item.ID, item.Op = i, op.ID, op.Op
+
+		if aborted {
+			item.Status = BulkStatusSkipped
+			continue
+		}
+
+		if r.Mode == BulkBestEffort {
+			if _, err := tx.Exec(`SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+		}
+
+		err := r.apply(tx, op, item)
+		if err != nil {
+			item.Status = BulkStatusFailed
+			item.Error = err.Error()
+			item.Before, item.After, item.Version, item.queued = nil, nil, 0, nil
+			result.Failed++
+
+			if r.Mode == BulkAtomic {
+				aborted = true
+				continue
+			}
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if r.Mode == BulkBestEffort {
+			if _, err := tx.Exec(`RELEASE SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+		}
+
+		item.Status = BulkStatusOK
+		result.Succeeded++
+	}
+
+	if aborted {
+		for i := range result.Results {
+			if result.Results[i].Status == BulkStatusOK {
+				result.Results[i].Status = BulkStatusRolledBack
+			}
+		}
+		result.Succeeded = 0
+		return result, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Committed = true
+
+	if result.Succeeded > 0 {
+		InvalidateAutocompleteCache()
+	}
+	for _, item := range result.Results {
+		if item.Status == BulkStatusOK {
+			publishEvents(item.queued)
+		}
+	}
+	return result, nil
+}
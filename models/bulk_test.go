@@ -0,0 +1,34 @@
+package models
+
+import (
+	"regexp"
+	"stock-dashboard/db"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBulkFilterMatchesNamesExactly(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT id FROM products WHERE 1=1 AND deleted_at IS NULL AND LOWER(name) = LOWER($1) AND category = $2 ORDER BY id LIMIT $3`)).
+		WithArgs("Green Tea", "Tea", MaxBulkOperations+1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectRollback()
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	request := BulkRequest{Filter: &ProductFilter{Name: "Green Tea", Category: "Tea"}, FilterOp: BulkOpDelete}
+	operations, err := request.expand(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 1 || operations[0].ID != 7 || operations[0].Op != BulkOpDelete {
+		t.Errorf("expand() = %+v, want a delete of product 7", operations)
+	}
+}
@@ -0,0 +1,252 @@
+package models
+
+import (
+	"log"
+	"math"
+	"sort"
+	"stock-dashboard/db"
+	"time"
+)
+
+type ClassificationOptions struct {
+	WindowDays int     `json:"windowDays"`
+	AThreshold float64 `json:"aThreshold"`
+	BThreshold float64 `json:"bThreshold"`
+	XThreshold float64 `json:"xThreshold"`
+	YThreshold float64 `json:"yThreshold"`
+}
+
+type ProductClassification struct {
+	ProductID        int64   `json:"productId"`
+	Name             string  `json:"name"`
+	Category         string  `json:"category"`
+	ConsumptionValue float64 `json:"consumptionValue"`
+	ValueShare       float64 `json:"valueShare"`
+	CumulativeShare  float64 `json:"cumulativeShare"`
+	DemandCV         float64 `json:"demandCv"`
+	ABCClass         string  `json:"abcClass"`
+	XYZClass         string  `json:"xyzClass"`
+}
+
+type ClassificationReport struct {
+	Options         ClassificationOptions   `json:"options"`
+	Classifications []ProductClassification `json:"classifications"`
+	Matrix          map[string]int          `json:"matrix"`
+}
+
+func (o *ClassificationOptions) normalize() {
+	if o.WindowDays <= 0 {
+		o.WindowDays = 90
+	}
+	if o.AThreshold <= 0 || o.AThreshold >= 1 {
+		o.AThreshold = 0.8
+	}
+	if o.BThreshold <= o.AThreshold || o.BThreshold >= 1 {
+		o.BThreshold = 0.95
+	}
+	if o.XThreshold <= 0 {
+		o.XThreshold = 0.5
+	}
+	if o.YThreshold <= o.XThreshold {
+		o.YThreshold = 1.0
+	}
+}
+
+func CoefficientOfVariation(series []float64) float64 {
+	if len(series) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range series {
+		sum += v
+	}
+	mean := sum / float64(len(series))
+	if mean == 0 {
+		return math.Inf(1)
+	}
+
+	var variance float64
+	for _, v := range series {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(series))
+
+	return math.Sqrt(variance) / mean
+}
+
+func weeklyTotals(daily []float64) []float64 {
+	weeks := make([]float64, (len(daily)+6)/7)
+	for i, v := range daily {
+		weeks[i/7] += v
+	}
+	return weeks
+}
+
+func ClassifyInventory(opts ClassificationOptions) (*ClassificationReport, error) {
+	opts.normalize()
+
+	today := startOfDay(time.Now())
+	start := today.AddDate(0, 0, -opts.WindowDays+1)
+
+	demand, err := loadDailyOutbound(start, opts.WindowDays)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(`SELECT id, name, category, price FROM products WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var classifications []ProductClassification
+	var totalValue float64
+	for rows.Next() {
+		var pc ProductClassification
+		var price float64
+		err := rows.Scan(&pc.ProductID, &pc.Name, &pc.Category, &price)
+		if err != nil {
+			return nil, err
+		}
+
+		series, ok := demand[pc.ProductID]
+		if !ok {
+			series = make([]float64, opts.WindowDays)
+		}
+
+		var consumed float64
+		for _, v := range series {
+			consumed += v
+		}
+		pc.ConsumptionValue = math.Round(consumed*price*100) / 100
+		totalValue += pc.ConsumptionValue
+
+		pc.DemandCV = CoefficientOfVariation(weeklyTotals(series))
+		switch {
+		case pc.DemandCV <= opts.XThreshold:
+			pc.XYZClass = "X"
+		case pc.DemandCV <= opts.YThreshold:
+			pc.XYZClass = "Y"
+		default:
+			pc.XYZClass = "Z"
+		}
+		if math.IsInf(pc.DemandCV, 1) {
+			pc.DemandCV = -1
+		} else {
+			pc.DemandCV = math.Round(pc.DemandCV*1000) / 1000
+		}
+
+		classifications = append(classifications, pc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(classifications, func(i, j int) bool {
+		return classifications[i].ConsumptionValue > classifications[j].ConsumptionValue
+	})
+
+	matrix := make(map[string]int)
+	var cumulative float64
+	for i := range classifications {
+		pc := &classifications[i]
+
+		if totalValue > 0 {
+			pc.ValueShare = pc.ConsumptionValue / totalValue
+		}
+		previous := cumulative
+		cumulative += pc.ValueShare
+		pc.CumulativeShare = math.Round(cumulative*10000) / 10000
+		pc.ValueShare = math.Round(pc.ValueShare*10000) / 10000
+
+		// A product belongs to the class its cumulative share starts in, so
+		// the single largest item is always an A even if it alone exceeds
+		// the A threshold.
+		switch {
+		case pc.ConsumptionValue == 0:
+			pc.ABCClass = "C"
+		case previous < opts.AThreshold:
+			pc.ABCClass = "A"
+		case previous < opts.BThreshold:
+			pc.ABCClass = "B"
+		default:
+			pc.ABCClass = "C"
+		}
+
+		matrix[pc.ABCClass+pc.XYZClass]++
+	}
+
+	if classifications == nil {
+		classifications = []ProductClassification{}
+	}
+
+	return &ClassificationReport{
+		Options:         opts,
+		Classifications: classifications,
+		Matrix:          matrix,
+	}, nil
+}
+
+func SaveClassifications(classifications []ProductClassification) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE products SET abc_class = $1, xyz_class = $2, classified_at = $3
+		WHERE id = $4
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, pc := range classifications {
+		_, err = stmt.Exec(pc.ABCClass, pc.XYZClass, now, pc.ProductID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func RecalculateClassifications(opts ClassificationOptions) (*ClassificationReport, error) {
+	report, err := ClassifyInventory(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = SaveClassifications(report.Classifications)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func StartClassificationScheduler(interval time.Duration, opts ClassificationOptions) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := RecalculateClassifications(opts)
+			if err != nil {
+				log.Printf("❌ Failed to recalculate ABC/XYZ classification: %v", err)
+			} else {
+				log.Printf("✅ ABC/XYZ classification recalculated for %d products", len(report.Classifications))
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql"
+	"math"
+	"stock-dashboard/db"
+	"time"
+)
+
+const (
+	StockStatusDead = "dead"
+	StockStatusSlow = "slow"
+)
+
+type DeadStockOptions struct {
+	Days          int         `json:"days"`
+	IncludeSlow   bool        `json:"includeSlow"`
+	SlowCoverDays int         `json:"slowCoverDays"`
+	Sort          []SortField `json:"sort"`
+}
+
+type DeadStockItem struct {
+	ProductID         int64      `json:"productId"`
+	Name              string     `json:"name"`
+	Category          string     `json:"category"`
+	Price             float64    `json:"price"`
+	Stock             int        `json:"stock"`
+	Value             float64    `json:"value"`
+	OutboundInWindow  int        `json:"outboundInWindow"`
+	LastOutboundAt    *time.Time `json:"lastOutboundAt"`
+	DaysSinceOutbound *int       `json:"daysSinceOutbound"`
+	DaysOfCover       *float64   `json:"daysOfCover"`
+	Status            string     `json:"status"`
+}
+
+func (o *DeadStockOptions) normalize() {
+	if o.Days <= 0 {
+		o.Days = 90
+	}
+	if o.SlowCoverDays <= 0 {
+		o.SlowCoverDays = 180
+	}
+	if len(o.Sort) == 0 {
+		o.Sort = []SortField{{Column: "value", Desc: true}}
+	}
+}
+
+func GetDeadStock(opts DeadStockOptions) ([]DeadStockItem, error) {
+	opts.normalize()
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -opts.Days)
+
+	query := `
+		SELECT id, name, category, price, stock, (price * stock), created_at, last_outbound_at, outbound_in_window
+		FROM (
+			SELECT p.id, p.name, p.category, p.price, p.stock, p.created_at, p.updated_at,
+				MAX(m.created_at) AS last_outbound_at,
+				COALESCE(SUM(m.quantity) FILTER (WHERE m.created_at >= $1), 0) AS outbound_in_window
+			FROM products p
+			LEFT JOIN stock_movements m ON m.product_id = p.id AND m.movement_type = 'outbound'
+			WHERE p.stock > 0 AND p.deleted_at IS NULL
+			GROUP BY p.id
+		) AS stock_activity
+	` + productOrderBy(opts.Sort)
+
+	rows, err := db.DB.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []DeadStockItem{}
+	for rows.Next() {
+		var item DeadStockItem
+		var createdAt time.Time
+		var lastOutbound sql.NullTime
+		err := rows.Scan(&item.ProductID, &item.Name, &item.Category, &item.Price, &item.Stock,
+			&item.Value, &createdAt, &lastOutbound, &item.OutboundInWindow)
+		if err != nil {
+			return nil, err
+		}
+
+		// A product added during the window has not had the whole window
+		// to sell, so it is too new to call dead or slow.
+		if createdAt.After(since) {
+			continue
+		}
+
+		item.Value = math.Round(item.Value*100) / 100
+		if lastOutbound.Valid {
+			item.LastOutboundAt = &lastOutbound.Time
+			days := int(now.Sub(lastOutbound.Time).Hours() / 24)
+			item.DaysSinceOutbound = &days
+		}
+
+		if item.OutboundInWindow == 0 {
+			item.Status = StockStatusDead
+		} else {
+			cover := math.Round(float64(item.Stock)/(float64(item.OutboundInWindow)/float64(opts.Days))*10) / 10
+			item.DaysOfCover = &cover
+			if !opts.IncludeSlow || cover < float64(opts.SlowCoverDays) {
+				continue
+			}
+			item.Status = StockStatusSlow
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetDeadStock(t *testing.T) {
+	columns := []string{"id", "name", "category", "price", "stock", "value", "created_at", "last_outbound_at",
+		"outbound_in_window"}
+	now := time.Now()
+	old := now.AddDate(-1, 0, 0)
+
+	mock := mockDB(t)
+	mock.ExpectQuery(`FROM products p`).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(1, "Old Tea", "Tea", 2.5, 10, 25.0, old, now.AddDate(0, 0, -120), 0).
+		AddRow(2, "New Tea", "Tea", 2.5, 10, 25.0, now.AddDate(0, 0, -5), nil, 0).
+		AddRow(3, "Never Sold", "Tea", 1.0, 4, 4.0, old, nil, 0).
+		AddRow(4, "Slow Tea", "Tea", 1.0, 900, 900.0, old, now.AddDate(0, 0, -1), 90).
+		AddRow(5, "New Slow Tea", "Tea", 1.0, 900, 900.0, now.AddDate(0, 0, -5), now.AddDate(0, 0, -1), 90).
+		AddRow(6, "Selling Tea", "Tea", 1.0, 10, 10.0, old, now.AddDate(0, 0, -1), 90))
+
+	items, err := GetDeadStock(DeadStockOptions{Days: 90, IncludeSlow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int64]string{1: StockStatusDead, 3: StockStatusDead, 4: StockStatusSlow}
+	got := make(map[int64]string)
+	for _, item := range items {
+		got[item.ProductID] = item.Status
+	}
+	if len(got) != len(want) {
+		t.Errorf("GetDeadStock() reported %v, want %v", got, want)
+	}
+	for id, status := range want {
+		if got[id] != status {
+			t.Errorf("product %d status = %q, want %q", id, got[id], status)
+		}
+	}
+}
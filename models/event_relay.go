@@ -0,0 +1,134 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"stock-dashboard/db"
+	"stock-dashboard/events"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	eventChannel = "stock_dashboard_events"
+
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+	maxNotifyPayload = 7999
+)
+
+type relayedEvent struct {
+	Origin string       `json:"origin"`
+	Event  events.Event `json:"event"`
+}
+
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("❌ Failed to generate instance ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// nextEventID draws the event's ID from a database sequence shared by all
+// instances, so SSE clients can resume from any of them.
+func nextEventID(q rowQuerier) (int64, error) {
+	var id int64
+	err := q.QueryRow(`SELECT nextval('event_id_seq')`).Scan(&id)
+	return id, err
+}
+
+// publish delivers an event numbered by queueEvents to local subscribers and
+// notifies the other instances through Postgres.
+func publish(event events.Event) {
+	events.Publish(event)
+	notifyPeers(event)
+}
+
+func notifyPeers(event events.Event) {
+	payload, err := json.Marshal(relayedEvent{Origin: instanceID, Event: event})
+	if err != nil {
+		log.Printf("❌ Failed to encode %s event for peers: %v", event.Type, err)
+		return
+	}
+	if len(payload) > maxNotifyPayload {
+		log.Printf("⚠️ %s event for product %d is too large to relay (%d bytes)", event.Type, event.ProductID, len(payload))
+		return
+	}
+
+	_, err = db.DB.Exec(`SELECT pg_notify($1, $2)`, eventChannel, string(payload))
+	if err != nil {
+		log.Printf("❌ Failed to relay %s event to peers: %v", event.Type, err)
+	}
+}
+
+func handleNotification(notification *pq.Notification) {
+	// A nil notification means the connection was re-established and
+	// anything sent meanwhile is lost, so drop what may now be stale.
+	if notification == nil {
+		InvalidateAutocompleteCache()
+		signalRevocation("")
+		return
+	}
+	if notification.Channel == sessionChannel {
+		signalRevocation(notification.Extra)
+		return
+	}
+
+	var relayed relayedEvent
+	err := json.Unmarshal([]byte(notification.Extra), &relayed)
+	if err != nil {
+		log.Printf("❌ Failed to decode relayed event: %v", err)
+		return
+	}
+	if relayed.Origin == instanceID {
+		return
+	}
+
+	event := relayed.Event
+	event.Remote = true
+
+	switch event.Type {
+	case events.ProductCreated, events.ProductUpdated, events.ProductDeleted:
+		InvalidateAutocompleteCache()
+	}
+	events.Publish(event)
+}
+
+// StartEventListener relays events published by other instances to local
+// subscribers. The pq listener reconnects on its own after connection loss.
+func StartEventListener() {
+	listener := pq.NewListener(db.ConnString(), 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventConnectionAttemptFailed, pq.ListenerEventDisconnected:
+			log.Printf("⚠️ Event listener connection lost: %v", err)
+		case pq.ListenerEventReconnected:
+			log.Printf("✅ Event listener reconnected")
+		}
+	})
+
+	for _, channel := range []string{eventChannel, sessionChannel} {
+		err := listener.Listen(channel)
+		if err != nil {
+			log.Fatalf("❌ Failed to listen on %s: %v", channel, err)
+		}
+	}
+
+	go func() {
+		ping := time.NewTicker(90 * time.Second)
+		defer ping.Stop()
+
+		for {
+			select {
+			case notification := <-listener.Notify:
+				handleNotification(notification)
+			case <-ping.C:
+				go listener.Ping()
+			}
+		}
+	}()
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"math"
+	"stock-dashboard/db"
+	"time"
+)
+
+const (
+	ForecastMovingAverage        = "sma"
+	ForecastExponentialSmoothing = "ses"
+)
+
+type ForecastOptions struct {
+	WindowDays   int     `json:"windowDays"`
+	Method       string  `json:"method"`
+	Alpha        float64 `json:"alpha"`
+	Seasonal     bool    `json:"seasonal"`
+	LeadTimeDays int     `json:"leadTimeDays"`
+	ReviewDays   int     `json:"reviewDays"`
+	SafetyDays   int     `json:"safetyDays"`
+	MinOrderQty  int     `json:"minOrderQty"`
+	PackSize     int     `json:"packSize"`
+	IncludeAll   bool    `json:"includeAll"`
+}
+
+type ReorderSuggestion struct {
+	ProductID      int64      `json:"productId"`
+	Name           string     `json:"name"`
+	Category       string     `json:"category"`
+	Stock          int        `json:"stock"`
+	DailyVelocity  float64    `json:"dailyVelocity"`
+	LeadTimeDemand float64    `json:"leadTimeDemand"`
+	ReorderPoint   int        `json:"reorderPoint"`
+	DaysOfCover    *float64   `json:"daysOfCover"`
+	StockoutDate   *time.Time `json:"stockoutDate"`
+	SuggestedQty   int        `json:"suggestedQty"`
+}
+
+func (o *ForecastOptions) normalize() {
+	if o.WindowDays <= 0 {
+		o.WindowDays = 56
+	}
+	if o.Method != ForecastMovingAverage {
+		o.Method = ForecastExponentialSmoothing
+	}
+	if o.Alpha <= 0 || o.Alpha > 1 {
+		o.Alpha = 0.3
+	}
+	if o.LeadTimeDays < 0 {
+		o.LeadTimeDays = 0
+	}
+	if o.ReviewDays < 0 {
+		o.ReviewDays = 0
+	}
+	if o.SafetyDays < 0 {
+		o.SafetyDays = 0
+	}
+	if o.MinOrderQty < 0 {
+		o.MinOrderQty = 0
+	}
+	if o.PackSize <= 0 {
+		o.PackSize = 1
+	}
+}
+
+func MovingAverage(series []float64, n int) float64 {
+	if len(series) == 0 {
+		return 0
+	}
+	if n <= 0 || n > len(series) {
+		n = len(series)
+	}
+
+	var sum float64
+	for _, v := range series[len(series)-n:] {
+		sum += v
+	}
+	return sum / float64(n)
+}
+
+func ExponentialSmoothing(series []float64, alpha float64) float64 {
+	if len(series) == 0 {
+		return 0
+	}
+
+	level := series[0]
+	for _, v := range series[1:] {
+		level = alpha*v + (1-alpha)*level
+	}
+	return level
+}
+
+// WeekdayIndices returns a multiplicative seasonal index per weekday, where
+// series[i] is the demand on start+i days. Weekdays without data get 1.
+func WeekdayIndices(series []float64, start time.Time) [7]float64 {
+	var sums [7]float64
+	var counts [7]int
+	var total float64
+
+	for i, v := range series {
+		day := start.AddDate(0, 0, i).Weekday()
+		sums[day] += v
+		counts[day]++
+		total += v
+	}
+
+	var indices [7]float64
+	for i := range indices {
+		indices[i] = 1
+	}
+	if total == 0 {
+		return indices
+	}
+
+	mean := total / float64(len(series))
+	for i := range indices {
+		if counts[i] > 0 {
+			indices[i] = (sums[i] / float64(counts[i])) / mean
+		}
+	}
+	return indices
+}
+
+// OrderQuantity turns a shortfall into something the supplier will accept:
+// at least minOrder units, rounded up to whole packs.
+func OrderQuantity(need float64, minOrder, packSize int) int {
+	if need <= 0 {
+		return 0
+	}
+	if packSize <= 0 {
+		packSize = 1
+	}
+
+	qty := max(int(math.Ceil(need)), minOrder)
+	return (qty + packSize - 1) / packSize * packSize
+}
+
+func velocity(series []float64, opts ForecastOptions) float64 {
+	if opts.Method == ForecastMovingAverage {
+		return MovingAverage(series, 0)
+	}
+	return ExponentialSmoothing(series, opts.Alpha)
+}
+
+func projectDemand(rate float64, from time.Time, days int, indices *[7]float64) float64 {
+	var demand float64
+	for i := 1; i <= days; i++ {
+		factor := 1.0
+		if indices != nil {
+			factor = indices[from.AddDate(0, 0, i).Weekday()]
+		}
+		demand += rate * factor
+	}
+	return demand
+}
+
+// startOfDay returns local midnight. Movement timestamps are stored as local
+// wall-clock time, so the window and the daily buckets must use it too.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// daysBetween counts calendar days from start to day, ignoring the location
+// each date was parsed in.
+func daysBetween(start, day time.Time) int {
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func loadDailyOutbound(start time.Time, windowDays int) (map[int64][]float64, error) {
+	query := `
+		SELECT product_id, DATE(created_at) AS day, SUM(quantity)
+		FROM stock_movements
+		WHERE movement_type = 'outbound' AND created_at >= $1
+		GROUP BY product_id, day
+	`
+
+	rows, err := db.DB.Query(query, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make(map[int64][]float64)
+	for rows.Next() {
+		var productID int64
+		var day time.Time
+		var quantity float64
+		err := rows.Scan(&productID, &day, &quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		offset := daysBetween(start, day)
+		if offset < 0 || offset >= windowDays {
+			continue
+		}
+		if _, ok := series[productID]; !ok {
+			series[productID] = make([]float64, windowDays)
+		}
+		series[productID][offset] += quantity
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// GetReorderSuggestions also returns the options after defaults were applied.
+func GetReorderSuggestions(opts ForecastOptions) ([]ReorderSuggestion, ForecastOptions, error) {
+	opts.normalize()
+
+	today := startOfDay(time.Now())
+	start := today.AddDate(0, 0, -opts.WindowDays+1)
+
+	demand, err := loadDailyOutbound(start, opts.WindowDays)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	rows, err := db.DB.Query(`SELECT id, name, category, stock FROM products WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, opts, err
+	}
+	defer rows.Close()
+
+	suggestions := []ReorderSuggestion{}
+	for rows.Next() {
+		var s ReorderSuggestion
+		err := rows.Scan(&s.ProductID, &s.Name, &s.Category, &s.Stock)
+		if err != nil {
+			return nil, opts, err
+		}
+
+		series, ok := demand[s.ProductID]
+		if !ok {
+			series = make([]float64, opts.WindowDays)
+		}
+
+		var indices *[7]float64
+		if opts.Seasonal {
+			weekday := WeekdayIndices(series, start)
+			indices = &weekday
+		}
+
+		rate := velocity(series, opts)
+		s.DailyVelocity = math.Round(rate*100) / 100
+		s.LeadTimeDemand = math.Round(projectDemand(rate, today, opts.LeadTimeDays, indices)*100) / 100
+
+		safetyStock := projectDemand(rate, today, opts.SafetyDays, indices)
+		s.ReorderPoint = int(math.Ceil(s.LeadTimeDemand + safetyStock))
+
+		if rate > 0 {
+			cover := math.Round(float64(s.Stock)/rate*10) / 10
+			s.DaysOfCover = &cover
+			stockout := today.Add(time.Duration(cover * 24 * float64(time.Hour)))
+			s.StockoutDate = &stockout
+		}
+
+		target := projectDemand(rate, today, opts.LeadTimeDays+opts.ReviewDays, indices) + safetyStock
+		if s.Stock <= s.ReorderPoint && rate > 0 {
+			s.SuggestedQty = OrderQuantity(target-float64(s.Stock), opts.MinOrderQty, opts.PackSize)
+		}
+
+		if s.SuggestedQty == 0 && !opts.IncludeAll {
+			continue
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, opts, err
+	}
+
+	return suggestions, opts, nil
+}
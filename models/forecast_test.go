@@ -0,0 +1,120 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMovingAverage(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []float64
+		n      int
+		want   float64
+	}{
+		{"empty", nil, 3, 0},
+		{"whole series", []float64{1, 2, 3, 6}, 0, 3},
+		{"last n", []float64{10, 1, 2, 3}, 3, 2},
+		{"n longer than series", []float64{2, 4}, 5, 3},
+		{"negative n", []float64{2, 4}, -1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MovingAverage(tt.series, tt.n); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("MovingAverage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExponentialSmoothing(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []float64
+		alpha  float64
+		want   float64
+	}{
+		{"empty", nil, 0.3, 0},
+		{"single value", []float64{5}, 0.3, 5},
+		{"alpha one follows the last value", []float64{1, 2, 9}, 1, 9},
+		{"alpha half", []float64{4, 8, 0}, 0.5, 3},
+		{"flat series", []float64{7, 7, 7, 7}, 0.3, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExponentialSmoothing(tt.series, tt.alpha); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ExponentialSmoothing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeekdayIndices(t *testing.T) {
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ones := [7]float64{1, 1, 1, 1, 1, 1, 1}
+
+	var twoWeeks []float64
+	for i := range 14 {
+		switch monday.AddDate(0, 0, i).Weekday() {
+		case time.Saturday, time.Sunday:
+			twoWeeks = append(twoWeeks, 20)
+		default:
+			twoWeeks = append(twoWeeks, 10)
+		}
+	}
+	weekday, weekend := 10/(180.0/14), 20/(180.0/14)
+
+	tests := []struct {
+		name   string
+		series []float64
+		want   [7]float64
+	}{
+		{"empty", nil, ones},
+		{"no demand", make([]float64, 14), ones},
+		{"busy weekends", twoWeeks, [7]float64{weekend, weekday, weekday, weekday, weekday, weekday, weekend}},
+		// Only Monday to Wednesday have data, the other days stay neutral.
+		{"partial week", []float64{2, 4, 6}, [7]float64{1, 0.5, 1, 1.5, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WeekdayIndices(tt.series, monday)
+			for day := range got {
+				if math.Abs(got[day]-tt.want[day]) > 1e-9 {
+					t.Errorf("WeekdayIndices()[%s] = %v, want %v", time.Weekday(day), got[day], tt.want[day])
+				}
+			}
+		})
+	}
+}
+
+func TestOrderQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		need     float64
+		minOrder int
+		packSize int
+		want     int
+	}{
+		{"nothing needed", 0, 50, 12, 0},
+		{"negative need", -4, 50, 12, 0},
+		{"rounds up a fraction", 10.2, 0, 1, 11},
+		{"raised to the minimum", 10, 50, 1, 50},
+		{"rounded up to whole packs", 25, 0, 12, 36},
+		{"minimum then packs", 10, 50, 12, 60},
+		{"exact pack multiple", 24, 0, 12, 24},
+		{"above the minimum", 70, 50, 1, 70},
+		{"pack size unset", 7, 0, 0, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrderQuantity(tt.need, tt.minOrder, tt.packSize); got != tt.want {
+				t.Errorf("OrderQuantity(%v, %d, %d) = %d, want %d", tt.need, tt.minOrder, tt.packSize, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"stock-dashboard/config"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"strings"
+	"time"
+)
+
+const (
+	RegistrationClosed = "closed"
+	RegistrationInvite = "invite"
+	RegistrationOpen   = "open"
+)
+
+type Invitation struct {
+	ID         int64      `json:"id"`
+	Email      string     `json:"email" binding:"required,email"`
+	Role       string     `json:"role" binding:"omitempty,oneof=admin staff"`
+	InvitedBy  *string    `json:"invitedBy"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvalidInvitation  = errors.New("invalid or expired invitation")
+)
+
+// RegistrationMode reads REGISTRATION_MODE. Sign-up is by invitation unless
+// REGISTRATION_MODE=open is set explicitly, and unknown values fall back to
+// closed.
+func RegistrationMode() string {
+	switch mode := config.GetEnv("REGISTRATION_MODE", RegistrationInvite); mode {
+	case RegistrationClosed, RegistrationInvite, RegistrationOpen:
+		return mode
+	default:
+		return RegistrationClosed
+	}
+}
+
+// Save stores the invitation and returns the token to send to the invitee.
+// Earlier pending invitations for the same email are revoked.
+func (i *Invitation) Save(ttl time.Duration) (string, error) {
+	i.Email = strings.ToLower(i.Email)
+	if i.Role == "" {
+		i.Role = "staff"
+	}
+
+	var exists bool
+	err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`, i.Email).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", ErrEmailTaken
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE invitations SET revoked_at = $1
+		WHERE email = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`, now, i.Email)
+	if err != nil {
+		return "", err
+	}
+
+	query := `
+		INSERT INTO invitations (email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	i.ExpiresAt = now.Add(ttl)
+	i.CreatedAt = now
+	err = tx.QueryRow(query, i.Email, i.Role, utils.HashToken(token), i.InvitedBy, i.ExpiresAt, i.CreatedAt).Scan(&i.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+func GetPendingInvitations() ([]Invitation, error) {
+	query := `
+		SELECT id, email, role, invited_by::text, expires_at, accepted_at, revoked_at, created_at
+		FROM invitations
+		WHERE accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.DB.Query(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		var i Invitation
+		err := rows.Scan(&i.ID, &i.Email, &i.Role, &i.InvitedBy, &i.ExpiresAt, &i.AcceptedAt, &i.RevokedAt, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+func (i *Invitation) Revoke() error {
+	query := `
+		UPDATE invitations SET revoked_at = $1
+		WHERE id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+		RETURNING email, role
+	`
+
+	err := db.DB.QueryRow(query, time.Now(), i.ID).Scan(&i.Email, &i.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// AcceptInvitation consumes the token and creates the invited user with the
+// given password.
+func AcceptInvitation(token, password string) (*User, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	user := User{Password: password}
+	err = tx.QueryRow(`
+		SELECT id, email, role FROM invitations
+		WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2
+		FOR UPDATE
+	`, utils.HashToken(token), time.Now()).Scan(&id, &user.Email, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = user.save(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE invitations SET accepted_at = $1 WHERE id = $2`, time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+	return &user, tx.Commit()
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"stock-dashboard/config"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"strings"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotStarted     = errors.New("two-factor enrollment has not been started")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+)
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type totpState struct {
+	secret      string
+	enabled     bool
+	lastCounter int64
+}
+
+func loadTOTPState(q rowQuerier, userID string, lock bool) (*totpState, error) {
+	query := `SELECT COALESCE(totp_secret, ''), totp_enabled, totp_last_counter FROM users WHERE id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var state totpState
+	var encrypted string
+	err := q.QueryRow(query, userID).Scan(&encrypted, &state.enabled, &state.lastCounter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if encrypted != "" {
+		state.secret, err = utils.DecryptSecret(encrypted)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+func IsMFAEnabled(userID string) (bool, error) {
+	var enabled bool
+	err := db.DB.QueryRow(`SELECT totp_enabled FROM users WHERE id = $1`, userID).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrUserNotFound
+	}
+	return enabled, err
+}
+
+// BeginTOTPEnrollment stores a fresh pending secret. It only takes effect
+// once EnableTOTP confirms the user can produce codes for it.
+func BeginTOTPEnrollment(userID, email string) (*TOTPEnrollment, error) {
+	enabled, err := IsMFAEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.DB.Exec(`UPDATE users SET totp_secret = $1, totp_last_counter = 0 WHERE id = $2`, encrypted, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := config.GetEnv("MFA_ISSUER", "Stock Dashboard")
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(issuer, email, secret),
+	}, nil
+}
+
+// EnableTOTP confirms enrollment with a code from the authenticator and
+// returns a fresh set of recovery codes.
+func EnableTOTP(userID, code string) ([]string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := loadTOTPState(tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if state.enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if state.secret == "" {
+		return nil, ErrMFANotStarted
+	}
+
+	counter, ok := utils.ValidateTOTP(state.secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	_, err = tx.Exec(`UPDATE users SET totp_enabled = TRUE, totp_last_counter = $1 WHERE id = $2`, counter, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, tx.Commit()
+}
+
+func DisableTOTP(userID string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_counter = 0,
+			token_version = token_version + 1
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	_, err = tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// VerifyMFA accepts either a current TOTP code or an unused recovery code.
+// TOTP codes are single-use: a step that already authenticated is rejected.
+func VerifyMFA(userID, code, recoveryCode string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state, err := loadTOTPState(tx, userID, true)
+	if err != nil {
+		return err
+	}
+	if !state.enabled {
+		return ErrMFANotEnabled
+	}
+
+	if recoveryCode != "" {
+		result, err := tx.Exec(`
+			UPDATE mfa_recovery_codes SET used_at = $1
+			WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+		`, time.Now(), userID, utils.HashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrInvalidMFACode
+		}
+		return tx.Commit()
+	}
+
+	counter, ok := utils.ValidateTOTP(state.secret, code, time.Now())
+	if !ok || counter <= state.lastCounter {
+		return ErrInvalidMFACode
+	}
+
+	_, err = tx.Exec(`UPDATE users SET totp_last_counter = $1 WHERE id = $2`, counter, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func RegenerateRecoveryCodes(userID string) ([]string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := loadTOTPState(tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !state.enabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+func CountRecoveryCodes(userID string) (int, error) {
+	var remaining int
+	err := db.DB.QueryRow(`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&remaining)
+	return remaining, err
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string) ([]string, error) {
+	_, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = raw[:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:]
+
+		_, err = tx.Exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+			userID, utils.HashToken(raw), time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	MovementInbound  = "inbound"
+	MovementOutbound = "outbound"
+)
+
+type StockMovement struct {
+	ID           int64     `json:"id"`
+	ProductID    int64     `json:"productId"`
+	MovementType string    `json:"movementType"`
+	Quantity     int       `json:"quantity"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func recordStockMovement(tx *sql.Tx, productID int64, delta int, at time.Time) error {
+	if delta == 0 {
+		return nil
+	}
+
+	movementType := MovementInbound
+	quantity := delta
+	if delta < 0 {
+		movementType = MovementOutbound
+		quantity = -delta
+	}
+
+	query := `
+		INSERT INTO stock_movements (product_id, movement_type, quantity, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := tx.Exec(query, productID, movementType, quantity, at)
+	return err
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"stock-dashboard/db"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrProvisioningDisabled = errors.New("no local account for this identity and it may not create one")
+
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Role is the role mapped from the provider's claims, or empty to leave
+	// the stored role alone.
+	Role string
+}
+
+// ProvisioningPolicy decides which unknown identities may get an account
+// on first sign-on. The zero value provisions nobody.
+type ProvisioningPolicy struct {
+	// Open lets any identity the provider vouches for create an account.
+	Open bool
+	// Invitations lets a verified email with a pending invitation create
+	// one, with the invited role.
+	Invitations bool
+	// AllowedDomains lets verified emails at these domains create one.
+	AllowedDomains []string
+}
+
+// FindOrProvisionOIDCUser resolves an identity to a local user: first by
+// its linked subject, then by verified email (linking the account), and
+// finally, if policy allows it, by creating a user without a local password.
+func FindOrProvisionOIDCUser(identity OIDCIdentity, policy ProvisioningPolicy) (*User, error) {
+	email := strings.ToLower(identity.Email)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user User
+	query := `SELECT id, email, name, role, disabled, totp_enabled, token_version FROM users`
+	scan := func(row *sql.Row) error {
+		return row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Disabled, &user.MFA, &user.TokenVersion)
+	}
+
+	err = scan(tx.QueryRow(query+` WHERE oidc_subject = $1 FOR UPDATE`, identity.Subject))
+	if errors.Is(err, sql.ErrNoRows) && email != "" && identity.EmailVerified {
+		err = scan(tx.QueryRow(query+` WHERE email = $1 AND oidc_subject IS NULL FOR UPDATE`, email))
+		if err == nil {
+			_, err = tx.Exec(`UPDATE users SET oidc_subject = $1 WHERE id = $2`, identity.Subject, user.ID)
+		}
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = provisionOIDCUser(tx, identity, email, policy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.Role != "" && identity.Role != user.Role {
+		// A role change revokes tokens issued under the old role.
+		err = tx.QueryRow(`
+			UPDATE users SET role = $1, token_version = token_version + 1 WHERE id = $2
+			RETURNING token_version
+		`, identity.Role, user.ID).Scan(&user.TokenVersion)
+		if err != nil {
+			return nil, err
+		}
+		user.Role = identity.Role
+	}
+
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	return &user, tx.Commit()
+}
+
+func provisionOIDCUser(tx *sql.Tx, identity OIDCIdentity, email string, policy ProvisioningPolicy) (User, error) {
+	if email == "" {
+		return User{}, ErrProvisioningDisabled
+	}
+
+	user := User{Email: email, Name: identity.Name, Role: "staff"}
+	allowed := policy.Open
+	var invitationID int64
+	if identity.EmailVerified && policy.Invitations {
+		err := tx.QueryRow(`
+			SELECT id, role FROM invitations
+			WHERE email = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2
+			ORDER BY created_at DESC LIMIT 1
+			FOR UPDATE
+		`, email, time.Now()).Scan(&invitationID, &user.Role)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return User{}, err
+		}
+		allowed = allowed || err == nil
+	}
+	if identity.EmailVerified && !allowed {
+		_, domain, _ := strings.Cut(email, "@")
+		allowed = slices.ContainsFunc(policy.AllowedDomains, func(d string) bool {
+			return strings.EqualFold(d, domain)
+		})
+	}
+	if !allowed {
+		return User{}, ErrProvisioningDisabled
+	}
+
+	if identity.Role != "" {
+		user.Role = identity.Role
+	}
+	err := tx.QueryRow(`
+		INSERT INTO users (email, name, password, role, oidc_subject)
+		VALUES ($1, $2, '', $3, $4)
+		RETURNING id
+	`, email, strings.TrimSpace(identity.Name), user.Role, identity.Subject).Scan(&user.ID)
+
+	// The email belongs to an account we could not safely link.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return User{}, ErrEmailTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	if invitationID != 0 {
+		_, err = tx.Exec(`UPDATE invitations SET accepted_at = $1 WHERE id = $2`, time.Now(), invitationID)
+	}
+	return user, err
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFindOrProvisionOIDCUserPolicy(t *testing.T) {
+	userColumns := []string{"id", "email", "name", "role", "disabled", "totp_enabled", "token_version"}
+	invited := []string{"id", "role"}
+
+	tests := []struct {
+		name       string
+		policy     ProvisioningPolicy
+		verified   bool
+		invitation *sqlmock.Rows
+		wantRole   string
+		wantErr    error
+	}{
+		{"closed registration", ProvisioningPolicy{}, true, nil, "", ErrProvisioningDisabled},
+		{"not invited", ProvisioningPolicy{Invitations: true}, true, sqlmock.NewRows(invited), "", ErrProvisioningDisabled},
+		{"invited", ProvisioningPolicy{Invitations: true}, true, sqlmock.NewRows(invited).AddRow(9, "admin"), "admin", nil},
+		{"allowed domain", ProvisioningPolicy{AllowedDomains: []string{"Example.com"}}, true, nil, "staff", nil},
+		{"allowed domain, unverified", ProvisioningPolicy{AllowedDomains: []string{"example.com"}}, false, nil, "", ErrProvisioningDisabled},
+		{"other domain", ProvisioningPolicy{AllowedDomains: []string{"example.org"}}, true, nil, "", ErrProvisioningDisabled},
+		{"open registration", ProvisioningPolicy{Open: true}, false, nil, "staff", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`WHERE oidc_subject = \$1`).WillReturnRows(sqlmock.NewRows(userColumns))
+			if tt.verified {
+				mock.ExpectQuery(`WHERE email = \$1 AND oidc_subject IS NULL`).WillReturnRows(sqlmock.NewRows(userColumns))
+			}
+			if tt.invitation != nil {
+				mock.ExpectQuery(`FROM invitations`).WithArgs("new@example.com", sqlmock.AnyArg()).WillReturnRows(tt.invitation)
+			}
+			if tt.wantErr == nil {
+				mock.ExpectQuery(`INSERT INTO users`).
+					WithArgs("new@example.com", "New User", tt.wantRole, "issuer|new").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("5"))
+				if tt.invitation != nil {
+					mock.ExpectExec(`UPDATE invitations SET accepted_at`).WithArgs(sqlmock.AnyArg(), 9).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			identity := OIDCIdentity{Subject: "issuer|new", Email: "New@example.com", EmailVerified: tt.verified, Name: "New User"}
+			user, err := FindOrProvisionOIDCUser(identity, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FindOrProvisionOIDCUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user.Role != tt.wantRole {
+				t.Errorf("role = %q, want %q", user.Role, tt.wantRole)
+			}
+		})
+	}
+}
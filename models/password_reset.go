@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"strings"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken issues a single-use token for the user with the
+// given email. It returns sql.ErrNoRows when no such user exists.
+func CreatePasswordResetToken(email string, ttl time.Duration) (string, *User, error) {
+	user := User{Email: strings.ToLower(email)}
+	err := db.DB.QueryRow(`SELECT id, role FROM users WHERE email = $1`, user.Email).Scan(&user.ID, &user.Role)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	now := time.Now()
+	_, err = db.DB.Exec(query, user.ID, utils.HashToken(token), now.Add(ttl), now)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &user, nil
+}
+
+// ResetPassword consumes the token, sets the new password and bumps the
+// user's token version so every token issued before stops working.
+func ResetPassword(token, password string) (string, error) {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(`
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		FOR UPDATE
+	`, utils.HashToken(token), time.Now()).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users SET password = $1, token_version = token_version + 1,
+			failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $2
+	`, hashedPassword, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Any other outstanding reset links for the user are spent as well.
+	_, err = tx.Exec(`UPDATE password_reset_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`, time.Now(), userID)
+	if err != nil {
+		return "", err
+	}
+
+	return userID, tx.Commit()
+}
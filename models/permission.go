@@ -0,0 +1,67 @@
+package models
+
+import "sort"
+
+const (
+	PermProductsRead     = "products:read"
+	PermProductsWrite    = "products:write"
+	PermProductsPurge    = "products:purge"
+	PermReportsRead      = "reports:read"
+	PermReportsRecompute = "reports:recalculate"
+	PermViewsManage      = "views:manage"
+	PermStaffRead        = "staff:read"
+	PermStaffManage      = "staff:manage"
+	PermUsersManage      = "users:manage"
+	PermAuditRead        = "audit:read"
+	PermWebhooksManage   = "webhooks:manage"
+	PermEventsRead       = "events:read"
+)
+
+var staffPermissions = []string{
+	PermProductsRead,
+	PermProductsWrite,
+	PermReportsRead,
+	PermViewsManage,
+	PermStaffRead,
+	PermEventsRead,
+}
+
+var adminPermissions = append([]string{
+	PermProductsPurge,
+	PermReportsRecompute,
+	PermStaffManage,
+	PermUsersManage,
+	PermAuditRead,
+	PermWebhooksManage,
+}, staffPermissions...)
+
+// APIKeyPermissions are the scopes an API key may be granted. Everything
+// else, including all admin routes, is reserved for signed-in users.
+var APIKeyPermissions = []string{
+	PermProductsRead,
+	PermProductsWrite,
+	PermReportsRead,
+	PermEventsRead,
+}
+
+// PermissionsForRole lists what a role may do, mirroring the route guards.
+func PermissionsForRole(role string) []string {
+	var perms []string
+	switch role {
+	case "admin":
+		perms = append(perms, adminPermissions...)
+	case "staff":
+		perms = append(perms, staffPermissions...)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+func HasPermission(role, permission string) bool {
+	for _, p := range PermissionsForRole(role) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{"staff", PermProductsWrite, true},
+		{"staff", PermStaffRead, true},
+		{"staff", PermStaffManage, false},
+		{"staff", PermUsersManage, false},
+		{"staff", PermProductsPurge, false},
+		{"admin", PermStaffManage, true},
+		{"admin", PermProductsRead, true},
+		{"api_key", PermProductsRead, false},
+		{"", PermProductsRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasPermission(tt.role, tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
@@ -1,21 +1,35 @@
 package models
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"stock-dashboard/db"
+	"stock-dashboard/events"
+	"stock-dashboard/utils"
 	"time"
 )
 
 type Product struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name" binding:"required"`
-	Price     float64   `json:"price" binding:"required,gt=0"`
-	Stock     int       `json:"stock" binding:"required,gt=0"`
-	Category  string    `json:"category" binding:"required"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        int64      `json:"id"`
+	Name      string     `json:"name" binding:"required"`
+	Price     float64    `json:"price" binding:"required,gt=0"`
+	Stock     int        `json:"stock" binding:"required,gt=0"`
+	Category  string     `json:"category" binding:"required"`
+	ABCClass  string     `json:"abcClass,omitempty"`
+	XYZClass  string     `json:"xyzClass,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy *string    `json:"deletedBy,omitempty"`
+	Version   int        `json:"version"`
 }
 
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVersionMismatch = errors.New("product version mismatch")
+)
+
 type ProductUpdate struct {
 	ID        int64     `json:"id,omitempty"`
 	Name      *string   `json:"name,omitempty"`
@@ -23,6 +37,20 @@ type ProductUpdate struct {
 	Stock     *int      `json:"stock,omitempty"`
 	Category  *string   `json:"category,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	Version   int       `json:"version"`
+
+	before Product
+	result Product
+}
+
+// Before returns the product as it was locked for the update.
+func (p *ProductUpdate) Before() Product {
+	return p.before
+}
+
+// After returns the product as written by the update.
+func (p *ProductUpdate) After() Product {
+	return p.result
 }
 
 type ProductFilter struct {
@@ -32,9 +60,17 @@ type ProductFilter struct {
 	MaxPrice  float64 `json:"max_price,omitempty"`
 	MinStock  int     `json:"min_stock,omitempty"`
 	MaxStock  int     `json:"max_stock,omitempty"`
+	ABCClass  string  `json:"abc_class,omitempty"`
+	XYZClass  string  `json:"xyz_class,omitempty"`
 	SortOrder string  `json:"sort_order,omitempty"`
+	Sort      string  `json:"sort,omitempty"`
 	Limit     int     `json:"limit,omitempty"`
 	Offset    int     `json:"offset,omitempty"`
+	Trashed   bool    `json:"-"`
+	// ExactMatch matches the name (ignoring case) and the category as a
+	// whole instead of as substrings, for writes that must not spill into
+	// similarly named products.
+	ExactMatch bool `json:"-"`
 }
 
 type ProductListResult struct {
@@ -45,14 +81,46 @@ type ProductListResult struct {
 	TotalPages int       `json:"total_pages"`
 }
 
+const productColumns = `id, name, price, stock, category,
+	COALESCE(abc_class, ''), COALESCE(xyz_class, ''), created_at, updated_at,
+	deleted_at, deleted_by::text, version`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (p *Product) scanTargets() []any {
+	return []any{&p.ID, &p.Name, &p.Price, &p.Stock, &p.Category,
+		&p.ABCClass, &p.XYZClass, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.DeletedBy, &p.Version}
+}
+
+func (p *Product) scan(row rowScanner) error {
+	return row.Scan(p.scanTargets()...)
+}
+
+func scanProducts(rows *sql.Rows) ([]Product, error) {
+	var products []Product
+	for rows.Next() {
+		var product Product
+		err := product.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
 func (p *Product) Get() error {
-	query := `
-		SELECT id, name, price, stock, category, created_at, updated_at 
-		FROM products WHERE id = $1
-	`
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1 AND deleted_at IS NULL`
 
 	row := db.DB.QueryRow(query, p.ID)
-	err := row.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.Category, &p.CreatedAt, &p.UpdatedAt)
+	err := p.scan(row)
 	if err != nil {
 		return err
 	}
@@ -63,22 +131,74 @@ func (p *Product) Save() error {
 	query := `
 		INSERT INTO products (name, price, stock, category, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
+		RETURNING id, version
 	`
 
 	now := time.Now()
 	p.CreatedAt = now
 	p.UpdatedAt = now
 
-	err := db.DB.QueryRow(query, p.Name, p.Price, p.Stock, p.Category, p.CreatedAt, p.UpdatedAt).Scan(&p.ID)
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(query, p.Name, p.Price, p.Stock, p.Category, p.CreatedAt, p.UpdatedAt).Scan(&p.ID, &p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = recordStockMovement(tx, p.ID, p.Stock, now)
+	if err != nil {
+		return err
+	}
+
+	queued, err := queueEvents(tx, productEvent(events.ProductCreated, *p))
 	if err != nil {
 		return err
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	InvalidateAutocompleteCache()
+	publishEvents(queued)
 	return nil
 }
 
 func (p *ProductUpdate) Update() error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = p.update(tx)
+	if err != nil {
+		return err
+	}
+
+	queued, err := queueEvents(tx, productChangeEvents(p.before.Stock, p.result)...)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	if p.Name != nil || p.Category != nil {
+		InvalidateAutocompleteCache()
+	}
+	publishEvents(queued)
+	return nil
+}
+
+func (p *ProductUpdate) update(tx *sql.Tx) error {
 	p.UpdatedAt = time.Now()
 
 	query := `UPDATE products SET `
@@ -106,94 +226,167 @@ func (p *ProductUpdate) Update() error {
 		argCount++
 	}
 
-	query += fmt.Sprintf("updated_at = $%d", argCount)
+	query += fmt.Sprintf("version = version + 1, updated_at = $%d", argCount)
 	args = append(args, p.UpdatedAt)
 	argCount++
 
-	query += fmt.Sprintf(" WHERE id = $%d", argCount)
+	query += fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL", argCount)
 	args = append(args, p.ID)
 
-	_, err := db.DB.Exec(query, args...)
-	return err
-}
+	before, err := lockProduct(tx, p.ID)
+	if err != nil {
+		return err
+	}
 
-func (p *Product) Delete() error {
-	query := `DELETE FROM products WHERE id = $1`
+	// A zero Version means the caller did not ask for a concurrency check.
+	if p.Version != 0 && p.Version != before.Version {
+		return ErrVersionMismatch
+	}
 
-	_, err := db.DB.Exec(query, p.ID)
+	err = p.result.scan(tx.QueryRow(query+" RETURNING "+productColumns, args...))
 	if err != nil {
 		return err
 	}
+	p.Version = p.result.Version
+	p.before = *before
 
+	if p.Stock != nil {
+		return recordStockMovement(tx, p.ID, *p.Stock-before.Stock, p.UpdatedAt)
+	}
 	return nil
 }
 
-func SearchProducts(searchTerm string, sortOrder string) ([]Product, error) {
-	var products []Product
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
 
-	order := "DESC"
-	if sortOrder == "asc" {
-		order = "ASC"
+// Delete soft-deletes the product and returns it as it was before.
+func (p *Product) Delete() (*Product, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	query := fmt.Sprintf(`
-		SELECT id, name, price, stock, category, created_at, updated_at 
-		FROM products 
-		WHERE name ILIKE $1 OR category ILIKE $1
-		ORDER BY created_at %s
-	`, order)
+	before, err := lockProduct(tx, p.ID)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := db.DB.Query(query, "%"+searchTerm+"%")
+	err = p.delete(tx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var product Product
-		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock,
-			&product.Category, &product.CreatedAt, &product.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		products = append(products, product)
+	queued, err := queueEvents(tx, productEvent(events.ProductDeleted, *p))
+	if err != nil {
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
+	err = tx.Commit()
+	if err != nil {
 		return nil, err
 	}
 
-	return products, nil
+	InvalidateAutocompleteCache()
+	publishEvents(queued)
+	return before, nil
 }
 
-func GetProductsWithPagination(filter ProductFilter) (*ProductListResult, error) {
-	if filter.Limit <= 0 {
-		filter.Limit = 10
+func (p *Product) delete(q rowQuerier) error {
+	now := time.Now()
+	query := `
+		UPDATE products SET deleted_at = $2, deleted_by = NULLIF($3, '')::integer, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)
+		RETURNING ` + productColumns
+
+	err := p.scan(q.QueryRow(query, p.ID, now, p.DeletedBy, p.Version))
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		err = q.QueryRow(`SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)`, p.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrVersionMismatch
+		}
+		return ErrProductNotFound
 	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
 
-	fmt.Println("Filter for pagination:", filter.MinPrice)
+func (p *Product) Restore() error {
+	query := `
+		UPDATE products SET deleted_at = NULL, deleted_by = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + productColumns
 
-	page := filter.Offset
-	if page <= 0 {
-		page = 1
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	var args []any
-	argCount := 0
+	err = p.scan(tx.QueryRow(query, p.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return err
+	}
 
-	countQuery := `SELECT COUNT(*) FROM products WHERE 1=1`
+	queued, err := queueEvents(tx, productEvent(events.ProductUpdated, *p))
+	if err != nil {
+		return err
+	}
 
-	dataQuery := `SELECT id, name, price, stock, category, created_at, updated_at FROM products WHERE 1=1`
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-	filterClause := ""
+	InvalidateAutocompleteCache()
+	publishEvents(queued)
+	return nil
+}
 
-	if filter.Name != "" {
+func PurgeDeletedProducts(olderThan time.Time) (int64, error) {
+	result, err := db.DB.Exec(`DELETE FROM products WHERE deleted_at IS NOT NULL AND deleted_at < $1`, olderThan)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (filter ProductFilter) whereClause() (string, []any) {
+	var args []any
+	argCount := 0
+
+	filterClause := " AND deleted_at IS NULL"
+	if filter.Trashed {
+		filterClause = " AND deleted_at IS NOT NULL"
+	}
+
+	if filter.Name != "" && filter.ExactMatch {
+		argCount++
+		filterClause += fmt.Sprintf(" AND LOWER(name) = LOWER($%d)", argCount)
+		args = append(args, filter.Name)
+	} else if filter.Name != "" {
 		argCount++
 		filterClause += fmt.Sprintf(" AND name ILIKE $%d", argCount)
 		args = append(args, "%"+filter.Name+"%")
 	}
 
-	if filter.Category != "" {
+	if filter.Category != "" && filter.ExactMatch {
+		argCount++
+		filterClause += fmt.Sprintf(" AND category = $%d", argCount)
+		args = append(args, filter.Category)
+	} else if filter.Category != "" {
 		argCount++
 		filterClause += fmt.Sprintf(" AND category ILIKE $%d", argCount)
 		args = append(args, "%"+filter.Category+"%")
@@ -223,6 +416,54 @@ func GetProductsWithPagination(filter ProductFilter) (*ProductListResult, error)
 		args = append(args, filter.MaxStock)
 	}
 
+	if filter.ABCClass != "" {
+		argCount++
+		filterClause += fmt.Sprintf(" AND abc_class = $%d", argCount)
+		args = append(args, filter.ABCClass)
+	}
+
+	if filter.XYZClass != "" {
+		argCount++
+		filterClause += fmt.Sprintf(" AND xyz_class = $%d", argCount)
+		args = append(args, filter.XYZClass)
+	}
+
+	return filterClause, args
+}
+
+func (filter ProductFilter) sortFields() ([]SortField, error) {
+	parse := ParseProductSort
+	if filter.Trashed {
+		parse = ParseTrashedProductSort
+	}
+
+	sort, err := parse(filter.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if len(sort) == 0 {
+		sort = DefaultProductSort(filter.SortOrder)
+	}
+	return sort, nil
+}
+
+func GetProductsWithPagination(filter ProductFilter) (*ProductListResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+
+	page := filter.Offset
+	if page <= 0 {
+		page = 1
+	}
+
+	countQuery := `SELECT COUNT(*) FROM products WHERE 1=1`
+
+	dataQuery := `SELECT ` + productColumns + ` FROM products WHERE 1=1`
+
+	filterClause, args := filter.whereClause()
+	argCount := len(args)
+
 	countQuery += filterClause
 	dataQuery += filterClause
 
@@ -232,11 +473,11 @@ func GetProductsWithPagination(filter ProductFilter) (*ProductListResult, error)
 		return nil, err
 	}
 
-	sortOrder := "DESC"
-	if filter.SortOrder == "asc" {
-		sortOrder = "ASC"
+	sort, err := filter.sortFields()
+	if err != nil {
+		return nil, err
 	}
-	dataQuery += fmt.Sprintf(" ORDER BY created_at %s", sortOrder)
+	dataQuery += productOrderBy(sort)
 
 	argCount++
 	dataQuery += fmt.Sprintf(" LIMIT $%d", argCount)
@@ -253,18 +494,8 @@ func GetProductsWithPagination(filter ProductFilter) (*ProductListResult, error)
 	}
 	defer rows.Close()
 
-	var products []Product
-	for rows.Next() {
-		var product Product
-		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock,
-			&product.Category, &product.CreatedAt, &product.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		products = append(products, product)
-	}
-
-	if err = rows.Err(); err != nil {
+	products, err := scanProducts(rows)
+	if err != nil {
 		return nil, err
 	}
 
@@ -281,3 +512,79 @@ func GetProductsWithPagination(filter ProductFilter) (*ProductListResult, error)
 		TotalPages: totalPages,
 	}, nil
 }
+
+type ProductCursorResult struct {
+	Products   []Product `json:"products"`
+	Limit      int       `json:"limit"`
+	NextCursor string    `json:"next_cursor"`
+	HasMore    bool      `json:"has_more"`
+	Total      *int      `json:"total,omitempty"`
+}
+
+func GetProductsWithCursor(filter ProductFilter, cursor string, includeTotal bool) (*ProductCursorResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+
+	sort, err := filter.sortFields()
+	if err != nil {
+		return nil, err
+	}
+
+	filterClause, args := filter.whereClause()
+	result := &ProductCursorResult{Limit: filter.Limit}
+
+	if includeTotal {
+		var total int
+		err := db.DB.QueryRow(`SELECT COUNT(*) FROM products WHERE 1=1`+filterClause, args...).Scan(&total)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	dataQuery := `SELECT ` + productColumns + ` FROM products WHERE 1=1` + filterClause
+
+	if cursor != "" {
+		var position productCursor
+		if err := utils.DecodeCursor(cursor, &position); err != nil {
+			return nil, err
+		}
+		if position.Sort != FormatProductSort(sort) || len(position.Values) != len(sort) {
+			return nil, utils.ErrInvalidCursor
+		}
+
+		keysetClause, keysetArgs := productKeysetClause(sort, position, len(args))
+		dataQuery += keysetClause
+		args = append(args, keysetArgs...)
+	}
+
+	dataQuery += productOrderBy(sort)
+	dataQuery += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	args = append(args, filter.Limit+1)
+
+	rows, err := db.DB.Query(dataQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products, err := scanProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(products) > filter.Limit {
+		products = products[:filter.Limit]
+		result.HasMore = true
+
+		next, err := utils.EncodeCursor(newProductCursor(sort, products[len(products)-1]))
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = next
+	}
+
+	result.Products = products
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql"
+	"stock-dashboard/config"
+	"stock-dashboard/events"
+	"time"
+)
+
+func LowStockThreshold() int {
+	return config.GetEnvInt("LOW_STOCK_THRESHOLD", 10)
+}
+
+func productEvent(eventType string, product Product) events.Event {
+	return events.Event{
+		Type:      eventType,
+		ProductID: product.ID,
+		Category:  product.Category,
+		Data:      product,
+	}
+}
+
+// productChangeEvents returns product.updated, stock.changed when the stock
+// level moved, and stock.low when it went from above the threshold to at or
+// below it.
+func productChangeEvents(previousStock int, product Product) []events.Event {
+	changes := []events.Event{productEvent(events.ProductUpdated, product)}
+
+	if previousStock != product.Stock {
+		changes = append(changes, events.Event{
+			Type:      events.StockChanged,
+			ProductID: product.ID,
+			Category:  product.Category,
+			Data: map[string]any{
+				"productId":     product.ID,
+				"stock":         product.Stock,
+				"previousStock": previousStock,
+			},
+		})
+	}
+
+	threshold := LowStockThreshold()
+	if previousStock > threshold && product.Stock <= threshold {
+		changes = append(changes, events.Event{
+			Type:      events.StockLow,
+			ProductID: product.ID,
+			Category:  product.Category,
+			Data: map[string]any{
+				"product":       product,
+				"previousStock": previousStock,
+				"threshold":     threshold,
+			},
+		})
+	}
+
+	return changes
+}
+
+// queueEvents numbers the events of a write and queues their webhook
+// deliveries in the write's transaction, so a crash after the commit cannot
+// lose them. Publish the returned events once the transaction commits.
+func queueEvents(tx *sql.Tx, pending ...events.Event) ([]events.Event, error) {
+	now := time.Now()
+	for i := range pending {
+		id, err := nextEventID(tx)
+		if err != nil {
+			return nil, err
+		}
+		pending[i].ID = id
+		pending[i].CreatedAt = now
+
+		err = enqueueWebhookDeliveries(tx, pending[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
+
+func publishEvents(committed []events.Event) {
+	for _, event := range committed {
+		publish(event)
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProductFilterMatchClause(t *testing.T) {
+	tests := []struct {
+		filter     ProductFilter
+		wantClause string
+		wantArgs   []any
+	}{
+		{
+			filter:     ProductFilter{Category: "Tea"},
+			wantClause: " AND deleted_at IS NULL AND category ILIKE $1",
+			wantArgs:   []any{"%Tea%"},
+		},
+		{
+			filter:     ProductFilter{Category: "Tea", ExactMatch: true},
+			wantClause: " AND deleted_at IS NULL AND category = $1",
+			wantArgs:   []any{"Tea"},
+		},
+		{
+			filter:     ProductFilter{Name: "Green Tea"},
+			wantClause: " AND deleted_at IS NULL AND name ILIKE $1",
+			wantArgs:   []any{"%Green Tea%"},
+		},
+		{
+			filter:     ProductFilter{Name: "Green Tea", Category: "Tea", ExactMatch: true},
+			wantClause: " AND deleted_at IS NULL AND LOWER(name) = LOWER($1) AND category = $2",
+			wantArgs:   []any{"Green Tea", "Tea"},
+		},
+		{
+			filter:     ProductFilter{Trashed: true, ExactMatch: true},
+			wantClause: " AND deleted_at IS NOT NULL",
+		},
+	}
+
+	for _, tt := range tests {
+		clause, args := tt.filter.whereClause()
+		if clause != tt.wantClause {
+			t.Errorf("whereClause() clause = %q, want %q", clause, tt.wantClause)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("whereClause() args = %v, want %v", args, tt.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"html"
+	"stock-dashboard/db"
+	"strings"
+)
+
+type ProductSearchHit struct {
+	Product
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type ProductSearchResult struct {
+	Hits       []ProductSearchHit `json:"products"`
+	Total      int                `json:"total"`
+	Page       int                `json:"page"`
+	Limit      int                `json:"limit"`
+	TotalPages int                `json:"total_pages"`
+}
+
+// ts_headline does not escape the text it highlights, so it marks matches
+// with private-use characters that highlightHTML swaps for <mark> tags once
+// the rest of the text has been escaped.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+
+	searchHeadlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+)
+
+var highlightTags = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+func highlightHTML(headline string) string {
+	return highlightTags.Replace(html.EscapeString(headline))
+}
+
+// SearchProducts matches the term against the products' tsvector and falls
+// back to trigram word similarity so misspellings still find results. Hits
+// are ranked by relevance unless an explicit sort is requested.
+func SearchProducts(searchTerm string, filter ProductFilter) (*ProductSearchResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+
+	page := filter.Offset
+	if page <= 0 {
+		page = 1
+	}
+
+	filterClause, args := filter.whereClause()
+	args = append(args, searchTerm)
+	term := len(args)
+
+	tsquery := fmt.Sprintf("websearch_to_tsquery('simple', $%d)", term)
+	matchClause := fmt.Sprintf(`
+		AND (search_vector @@ %[1]s
+			OR name ILIKE '%%' || $%[2]d || '%%'
+			OR category ILIKE '%%' || $%[2]d || '%%'
+			OR $%[2]d <%% name
+			OR $%[2]d <%% category)`, tsquery, term)
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM products WHERE 1=1` + filterClause + matchClause
+	err := db.DB.QueryRow(countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy := " ORDER BY rank DESC, id ASC"
+	if filter.Sort != "" || filter.SortOrder != "" {
+		sort, err := ParseProductSort(filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if len(sort) == 0 {
+			sort = DefaultProductSort(filter.SortOrder)
+		}
+		orderBy = productOrderBy(sort)
+	}
+
+	dataQuery := fmt.Sprintf(`
+		SELECT %[1]s,
+			ts_rank_cd(search_vector, %[2]s) + word_similarity($%[3]d, name) AS rank,
+			ts_headline('simple', name, %[2]s, '%[4]s'),
+			ts_headline('simple', category, %[2]s, '%[4]s')
+		FROM products
+		WHERE 1=1`, productColumns, tsquery, term, searchHeadlineOptions)
+	dataQuery += filterClause + matchClause + orderBy
+	dataQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, (page-1)*filter.Limit)
+
+	rows, err := db.DB.Query(dataQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []ProductSearchHit{}
+	for rows.Next() {
+		var hit ProductSearchHit
+		var nameHighlight, categoryHighlight string
+		targets := append(hit.scanTargets(), &hit.Rank, &nameHighlight, &categoryHighlight)
+		err := rows.Scan(targets...)
+		if err != nil {
+			return nil, err
+		}
+
+		hit.Highlights = map[string]string{
+			"name":     highlightHTML(nameHighlight),
+			"category": highlightHTML(categoryHighlight),
+		}
+		hits = append(hits, hit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	totalPages := (total + filter.Limit - 1) / filter.Limit
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	return &ProductSearchResult{
+		Hits:       hits,
+		Total:      total,
+		Page:       page,
+		Limit:      filter.Limit,
+		TotalPages: totalPages,
+	}, nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import "testing"
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		headline string
+		want     string
+	}{
+		{"Green " + highlightStart + "Tea" + highlightStop, "Green <mark>Tea</mark>"},
+		{"<img src=x onerror=alert(1)>", "&lt;img src=x onerror=alert(1)&gt;"},
+		{highlightStart + "<b>" + highlightStop + " & co", "<mark>&lt;b&gt;</mark> &amp; co"},
+		{`"quoted" 'tea'`, "&#34;quoted&#34; &#39;tea&#39;"},
+	}
+
+	for _, tt := range tests {
+		if got := highlightHTML(tt.headline); got != tt.want {
+			t.Errorf("highlightHTML(%q) = %q, want %q", tt.headline, got, tt.want)
+		}
+	}
+}
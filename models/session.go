@@ -0,0 +1,257 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"stock-dashboard/db"
+	"sync"
+	"time"
+)
+
+const (
+	SessionPassword = "password"
+	SessionMFA      = "mfa"
+	SessionOIDC     = "oidc"
+
+	// SessionLifetime matches the lifetime of the access tokens.
+	SessionLifetime = 24 * time.Hour
+
+	maxUserAgentLength = 512
+)
+
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"userId"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	Method     string    `json:"method"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+var ErrSessionNotFound = errors.New("session not found")
+
+const sessionChannel = "stock_dashboard_sessions"
+
+var (
+	revocationMu       sync.Mutex
+	revocationWatchers = make(map[string]map[chan struct{}]struct{})
+)
+
+// WatchRevocations lets a long-lived request such as an event stream learn
+// right away that sessions of the user were signed out on any instance, so
+// it can re-check its own. Call stop once done watching.
+func WatchRevocations(userID string) (revoked <-chan struct{}, stop func()) {
+	ch := make(chan struct{}, 1)
+
+	revocationMu.Lock()
+	if revocationWatchers[userID] == nil {
+		revocationWatchers[userID] = make(map[chan struct{}]struct{})
+	}
+	revocationWatchers[userID][ch] = struct{}{}
+	revocationMu.Unlock()
+
+	return ch, func() {
+		revocationMu.Lock()
+		delete(revocationWatchers[userID], ch)
+		if len(revocationWatchers[userID]) == 0 {
+			delete(revocationWatchers, userID)
+		}
+		revocationMu.Unlock()
+	}
+}
+
+// signalRevocation wakes the watchers of userID, or every watcher when
+// userID is empty.
+func signalRevocation(userID string) {
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+
+	for id, watchers := range revocationWatchers {
+		if userID != "" && id != userID {
+			continue
+		}
+		for ch := range watchers {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// notifyRevocation reaches every instance, this one included, through the
+// event listener. Inside a transaction it is only sent on commit.
+func notifyRevocation(q execer, userID string) error {
+	_, err := q.Exec(`SELECT pg_notify($1, $2)`, sessionChannel, userID)
+	return err
+}
+
+func (s *Session) Save(tokenVersion int) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	s.ID = hex.EncodeToString(b)
+
+	if len(s.UserAgent) > maxUserAgentLength {
+		s.UserAgent = s.UserAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	s.CreatedAt = now
+	s.LastSeenAt = now
+	s.ExpiresAt = now.Add(SessionLifetime)
+
+	query := `
+		INSERT INTO sessions (id, user_id, user_agent, ip, method, token_version, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8)
+	`
+
+	_, err := db.DB.Exec(query, s.ID, s.UserID, s.UserAgent, s.IP, s.Method, tokenVersion, now, s.ExpiresAt)
+	return err
+}
+
+// activeSessionClause keeps sessions that are neither revoked, expired nor
+// invalidated by a token version bump such as a password change.
+const activeSessionClause = `
+	s.revoked_at IS NULL AND s.expires_at > $2
+	AND s.token_version = (SELECT token_version FROM users WHERE id = s.user_id)`
+
+// TouchSession reports whether the session is still active and records
+// activity on it, at most once a minute.
+func TouchSession(id, userID string) error {
+	now := time.Now()
+
+	var lastSeen time.Time
+	err := db.DB.QueryRow(`
+		SELECT s.last_seen_at FROM sessions s
+		WHERE s.id = $1 AND `+activeSessionClause+` AND s.user_id = $3
+	`, id, now, userID).Scan(&lastSeen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if now.Sub(lastSeen) >= time.Minute {
+		_, err = db.DB.Exec(`UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, now, id)
+	}
+	return err
+}
+
+// RefreshSession moves a session onto the user's new token version after a
+// change that revoked their tokens, so the session that made the change
+// stays listed.
+func RefreshSession(id string, tokenVersion int) error {
+	_, err := db.DB.Exec(`
+		UPDATE sessions SET token_version = $1, expires_at = $2
+		WHERE id = $3 AND revoked_at IS NULL
+	`, tokenVersion, time.Now().Add(SessionLifetime), id)
+	return err
+}
+
+func GetActiveSessions(userID string) ([]Session, error) {
+	rows, err := db.DB.Query(`
+		SELECT s.id, s.user_id, s.user_agent, s.ip, s.method, s.created_at, s.last_seen_at, s.expires_at
+		FROM sessions s
+		WHERE s.user_id = $1 AND `+activeSessionClause+`
+		ORDER BY s.last_seen_at DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.Method, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func RevokeSession(id, userID string) error {
+	result, err := db.DB.Exec(`
+		UPDATE sessions SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	// Open streams also re-check periodically, so a lost notification only
+	// delays the sign-out.
+	if err := notifyRevocation(db.DB, userID); err != nil {
+		log.Printf("❌ Failed to announce sign-out of session %s: %v", id, err)
+	}
+	return nil
+}
+
+// RevokeAllSessions signs the user out everywhere. Bumping the token version
+// also kills tokens issued before sessions were tracked.
+func RevokeAllSessions(userID string) (int64, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, ErrUserNotFound
+	}
+
+	result, err = tx.Exec(`
+		UPDATE sessions SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL AND expires_at > $1
+	`, time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	err = notifyRevocation(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, tx.Commit()
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func fired(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(10 * time.Millisecond):
+		return false
+	}
+}
+
+func TestWatchRevocations(t *testing.T) {
+	alice, stopAlice := WatchRevocations("1")
+	defer stopAlice()
+	bob, stopBob := WatchRevocations("2")
+
+	signalRevocation("1")
+	if !fired(alice) {
+		t.Error("watcher of user 1 was not signalled")
+	}
+	if fired(bob) {
+		t.Error("watcher of user 2 was signalled for user 1")
+	}
+
+	signalRevocation("")
+	if !fired(alice) || !fired(bob) {
+		t.Error("signalling everyone missed a watcher")
+	}
+
+	stopBob()
+	signalRevocation("2")
+	if fired(bob) {
+		t.Error("stopped watcher was signalled")
+	}
+	if _, ok := revocationWatchers["2"]; ok {
+		t.Error("stopping the last watcher left an entry behind")
+	}
+}
+
+func TestRevokeSessionNotifiesInstances(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectExec(`UPDATE sessions SET revoked_at`).
+		WithArgs(sqlmock.AnyArg(), "abc", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT pg_notify`).
+		WithArgs(sessionChannel, "1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE sessions SET revoked_at`).
+		WithArgs(sqlmock.AnyArg(), "gone", "1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := RevokeSession("abc", "1"); err != nil {
+		t.Errorf("RevokeSession() = %v, want nil", err)
+	}
+	if err := RevokeSession("gone", "1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeSession() = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET token_version = token_version \+ 1`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE sessions SET revoked_at`).
+		WithArgs(sqlmock.AnyArg(), "1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`SELECT pg_notify`).
+		WithArgs(sessionChannel, "1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	revoked, err := RevokeAllSessions("1")
+	if err != nil || revoked != 3 {
+		t.Errorf("RevokeAllSessions() = %d, %v; want 3, nil", revoked, err)
+	}
+}
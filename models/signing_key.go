@@ -0,0 +1,213 @@
+package models
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"stock-dashboard/config"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"time"
+)
+
+// signingKeyLock serialises rotation across instances.
+const signingKeyLock = 4242001
+
+// keyActivationDelay is how long a rotated-in key is only published before
+// it starts signing. Two JWKS cache windows leave room for clients whose
+// cached copy was fetched just before the key appeared.
+const keyActivationDelay = 2 * utils.JWKSMaxAge
+
+type KeyRotationOptions struct {
+	Algorithm string
+	// RotateEvery is the age at which the active key is replaced.
+	RotateEvery time.Duration
+	// Overlap is how long a retired key keeps verifying tokens. It should be
+	// at least the access token lifetime.
+	Overlap time.Duration
+}
+
+// SigningKeyOptions reads JWT_SIGNING_ALG (RS256, EdDSA or HS256 to keep
+// the shared secret), JWT_KEY_ROTATION_DAYS and JWT_KEY_OVERLAP_HOURS.
+func SigningKeyOptions() KeyRotationOptions {
+	return KeyRotationOptions{
+		Algorithm:   config.GetEnv("JWT_SIGNING_ALG", utils.AlgRS256),
+		RotateEvery: time.Duration(config.GetEnvInt("JWT_KEY_ROTATION_DAYS", 30)) * 24 * time.Hour,
+		Overlap:     time.Duration(config.GetEnvInt("JWT_KEY_OVERLAP_HOURS", 48)) * time.Hour,
+	}
+}
+
+// LoadSigningKeys reads every key that can still verify tokens into the
+// in-memory keyring.
+func LoadSigningKeys() error {
+	rows, err := db.DB.Query(`
+		SELECT kid, algorithm, private_key, created_at, COALESCE(activates_at, created_at), retired_at, expires_at
+		FROM jwt_signing_keys
+		WHERE expires_at IS NULL OR expires_at > $1
+		ORDER BY created_at
+	`, time.Now())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var keys []utils.SigningKey
+	for rows.Next() {
+		var key utils.SigningKey
+		var sealed string
+		err := rows.Scan(&key.Kid, &key.Algorithm, &sealed, &key.CreatedAt, &key.ActivatesAt, &key.RetiredAt,
+			&key.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		key.Private, err = openSigningKey(sealed)
+		if err != nil {
+			log.Printf("❌ Skipping unreadable signing key %s: %v", key.Kid, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	utils.SetSigningKeys(keys)
+	return nil
+}
+
+func sealSigningKey(private crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	return utils.EncryptSecret(base64.StdEncoding.EncodeToString(der))
+}
+
+func openSigningKey(sealed string) (crypto.Signer, error) {
+	encoded, err := utils.DecryptSecret(sealed)
+	if err != nil {
+		return nil, err
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("stored key cannot sign")
+	}
+	return signer, nil
+}
+
+// EnsureSigningKey rotates in a new key when there is no current key, the
+// current one is older than RotateEvery, or the algorithm changed. With
+// force it rotates regardless. It returns the kid of the newest key.
+//
+// A new key that replaces another only starts signing after
+// keyActivationDelay, so it is in every cached JWKS before tokens signed
+// with it appear. The keys it replaces keep signing until then.
+func EnsureSigningKey(opts KeyRotationOptions, force bool) (string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, signingKeyLock)
+	if err != nil {
+		return "", err
+	}
+
+	var kid, algorithm string
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		SELECT kid, algorithm, created_at FROM jwt_signing_keys
+		WHERE retired_at IS NULL
+		ORDER BY created_at DESC LIMIT 1
+	`).Scan(&kid, &algorithm, &createdAt)
+	current := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	if current && !force && algorithm == opts.Algorithm && time.Since(createdAt) < opts.RotateEvery {
+		return kid, nil
+	}
+
+	private, err := utils.GenerateSigningKey(opts.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := sealSigningKey(private)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	newKid := hex.EncodeToString(b)
+
+	// Without a current key nothing is signing yet, so there is no cached
+	// JWKS to wait for.
+	now := time.Now()
+	activatesAt := now
+	if current {
+		activatesAt = now.Add(keyActivationDelay)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE jwt_signing_keys SET retired_at = $1, expires_at = $2
+		WHERE retired_at IS NULL
+	`, activatesAt, activatesAt.Add(opts.Overlap))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO jwt_signing_keys (kid, algorithm, private_key, created_at, activates_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, newKid, opts.Algorithm, sealed, now, activatesAt)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	log.Printf("🔑 Rotated in JWT signing key %s (%s), signing from %s", newKid, opts.Algorithm,
+		activatesAt.Format(time.RFC3339))
+	return newKid, nil
+}
+
+// StartSigningKeyRotation checks hourly whether the key is due for
+// rotation and reloads the keyring, which also picks up keys rotated by
+// other instances.
+func StartSigningKeyRotation(opts KeyRotationOptions) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := EnsureSigningKey(opts, false); err != nil {
+				log.Printf("❌ Failed to rotate JWT signing key: %v", err)
+			}
+			if err := LoadSigningKeys(); err != nil {
+				log.Printf("❌ Failed to reload JWT signing keys: %v", err)
+			}
+		}
+	}()
+}
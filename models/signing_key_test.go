@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql/driver"
+	"stock-dashboard/utils"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// within matches a timestamp argument close to want.
+type within struct {
+	want time.Time
+}
+
+func (w within) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Sub(w.want).Abs() < 5*time.Second
+}
+
+func TestEnsureSigningKeyDelaysActivation(t *testing.T) {
+	t.Setenv("MFA_ENCRYPTION_KEY", "test-encryption-key")
+	opts := KeyRotationOptions{Algorithm: utils.AlgEdDSA, RotateEvery: 24 * time.Hour, Overlap: time.Hour}
+
+	tests := []struct {
+		name       string
+		current    *sqlmock.Rows
+		activation time.Duration
+	}{
+		{"first key signs at once", sqlmock.NewRows([]string{"kid", "algorithm", "created_at"}), 0},
+		{"replacement waits for JWKS caches", sqlmock.NewRows([]string{"kid", "algorithm", "created_at"}).
+			AddRow("old", utils.AlgEdDSA, time.Now().Add(-48*time.Hour)), keyActivationDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activatesAt := time.Now().Add(tt.activation)
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(`pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`SELECT kid, algorithm, created_at FROM jwt_signing_keys`).WillReturnRows(tt.current)
+			mock.ExpectExec(`UPDATE jwt_signing_keys SET retired_at = \$1, expires_at = \$2`).
+				WithArgs(within{activatesAt}, within{activatesAt.Add(opts.Overlap)}).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`INSERT INTO jwt_signing_keys`).
+				WithArgs(sqlmock.AnyArg(), utils.AlgEdDSA, sqlmock.AnyArg(), within{time.Now()}, within{activatesAt}).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			if _, err := EnsureSigningKey(opts, false); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SortField struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc"`
+}
+
+var productSortColumns = map[string]string{
+	"name":       "name",
+	"price":      "price",
+	"stock":      "stock",
+	"category":   "category",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"deleted_at": "deleted_at",
+	"value":      "(price * stock)",
+}
+
+// trashOnlySortColumns are NULL for live products, where a keyset cursor
+// could not compare them.
+var trashOnlySortColumns = map[string]bool{
+	"deleted_at": true,
+}
+
+func ParseProductSort(raw string) ([]SortField, error) {
+	return parseProductSort(raw, false)
+}
+
+func ParseTrashedProductSort(raw string) ([]SortField, error) {
+	return parseProductSort(raw, true)
+}
+
+func parseProductSort(raw string, trashed bool) ([]SortField, error) {
+	var fields []SortField
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{}
+		if strings.HasPrefix(part, "-") {
+			field.Desc = true
+			part = part[1:]
+		} else {
+			part = strings.TrimPrefix(part, "+")
+		}
+
+		column := strings.ToLower(part)
+		if _, ok := productSortColumns[column]; !ok || (trashOnlySortColumns[column] && !trashed) {
+			return nil, fmt.Errorf("unsupported sort column %q", part)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate sort column %q", part)
+		}
+		seen[column] = true
+
+		field.Column = column
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func DefaultProductSort(sortOrder string) []SortField {
+	return []SortField{{Column: "created_at", Desc: sortOrder != "asc"}}
+}
+
+func FormatProductSort(fields []SortField) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.Column
+		if f.Desc {
+			parts[i] = "-" + f.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func productOrderBy(fields []SortField) string {
+	if len(fields) == 0 {
+		fields = DefaultProductSort("desc")
+	}
+
+	terms := make([]string, 0, len(fields)+1)
+	for _, f := range fields {
+		terms = append(terms, productSortColumns[f.Column]+" "+sortDirection(f.Desc))
+	}
+	terms = append(terms, "id "+sortDirection(fields[0].Desc))
+
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+func sortDirection(desc bool) string {
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+type productCursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	ID     int64    `json:"id"`
+}
+
+func productSortValue(p Product, column string) string {
+	switch column {
+	case "name":
+		return p.Name
+	case "price":
+		return strconv.FormatFloat(p.Price, 'f', -1, 64)
+	case "stock":
+		return strconv.Itoa(p.Stock)
+	case "category":
+		return p.Category
+	case "created_at":
+		return p.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return p.UpdatedAt.Format(time.RFC3339Nano)
+	case "deleted_at":
+		if p.DeletedAt != nil {
+			return p.DeletedAt.Format(time.RFC3339Nano)
+		}
+	case "value":
+		return strconv.FormatFloat(math.Round(p.Price*float64(p.Stock)*100)/100, 'f', 2, 64)
+	}
+	return ""
+}
+
+func newProductCursor(sort []SortField, last Product) productCursor {
+	cursor := productCursor{Sort: FormatProductSort(sort), ID: last.ID}
+	for _, f := range sort {
+		cursor.Values = append(cursor.Values, productSortValue(last, f.Column))
+	}
+	return cursor
+}
+
+// productKeysetClause builds the "rows after the cursor" predicate for an
+// ORDER BY produced by productOrderBy, expanding the row comparison so each
+// column can have its own direction.
+func productKeysetClause(sort []SortField, cursor productCursor, argCount int) (string, []any) {
+	type key struct {
+		expr  string
+		desc  bool
+		value any
+	}
+
+	keys := make([]key, 0, len(sort)+1)
+	for i, f := range sort {
+		keys = append(keys, key{productSortColumns[f.Column], f.Desc, cursor.Values[i]})
+	}
+	keys = append(keys, key{"id", sort[0].Desc, cursor.ID})
+
+	var args []any
+	var alternatives []string
+	for i, k := range keys {
+		var terms []string
+		for _, prev := range keys[:i] {
+			argCount++
+			terms = append(terms, fmt.Sprintf("%s = $%d", prev.expr, argCount))
+			args = append(args, prev.value)
+		}
+
+		op := ">"
+		if k.desc {
+			op = "<"
+		}
+		argCount++
+		terms = append(terms, fmt.Sprintf("%s %s $%d", k.expr, op, argCount))
+		args = append(args, k.value)
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return " AND (" + strings.Join(alternatives, " OR ") + ")", args
+}
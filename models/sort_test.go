@@ -0,0 +1,50 @@
+package models
+
+import "testing"
+
+func TestParseProductSort(t *testing.T) {
+	tests := []struct {
+		raw     string
+		trashed bool
+		want    string
+		wantErr bool
+	}{
+		{raw: "", want: ""},
+		{raw: "-price, name", want: "-price,name"},
+		{raw: "+Stock", want: "stock"},
+		{raw: "price,-price", wantErr: true},
+		{raw: "password", wantErr: true},
+		{raw: "-deleted_at", wantErr: true},
+		{raw: "-deleted_at", trashed: true, want: "-deleted_at"},
+	}
+
+	for _, tt := range tests {
+		parse := ParseProductSort
+		if tt.trashed {
+			parse = ParseTrashedProductSort
+		}
+		fields, err := parse(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parse(%q, trashed=%v) error = %v, wantErr %v", tt.raw, tt.trashed, err, tt.wantErr)
+			continue
+		}
+		if got := FormatProductSort(fields); err == nil && got != tt.want {
+			t.Errorf("parse(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestProductKeysetClause(t *testing.T) {
+	sort := []SortField{{Column: "price", Desc: true}, {Column: "name"}}
+	cursor := productCursor{Values: []string{"9.5", "Tea"}, ID: 42}
+
+	clause, args := productKeysetClause(sort, cursor, 2)
+
+	want := " AND ((price < $3) OR (price = $4 AND name > $5) OR (price = $6 AND name = $7 AND id < $8))"
+	if clause != want {
+		t.Errorf("clause = %q, want %q", clause, want)
+	}
+	if len(args) != 6 || args[0] != "9.5" || args[5] != int64(42) {
+		t.Errorf("unexpected args %v", args)
+	}
+}
@@ -1,49 +1,181 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"stock-dashboard/config"
 	"stock-dashboard/db"
 	"stock-dashboard/utils"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type User struct {
 	ID       string `json:"id"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty" binding:"required"`
 	Role     string `json:"role,omitempty"`
+	Disabled bool   `json:"disabled"`
+	MFA      bool   `json:"mfaEnabled"`
+
+	LockedUntil  *time.Time `json:"lockedUntil,omitempty"`
+	TokenVersion int        `json:"-"`
 }
 
 type UserUpdate struct {
-	ID       string `json:"id"`
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
-	Role     string `json:"role,omitempty"`
+	ID       string  `json:"id"`
+	Email    string  `json:"email,omitempty" binding:"omitempty,email"`
+	Name     *string `json:"name,omitempty" binding:"omitempty,max=255"`
+	Password string  `json:"password,omitempty" binding:"omitempty,min=8"`
+	Role     string  `json:"role,omitempty" binding:"omitempty,oneof=admin staff"`
+	Disabled *bool   `json:"disabled,omitempty"`
+
+	revoked bool
 }
 
+// Revoked reports whether the update revoked the user's existing tokens.
+func (u *UserUpdate) Revoked() bool {
+	return u.revoked
+}
+
+type UserFilter struct {
+	Search   string
+	Role     string
+	Disabled *bool
+	Limit    int
+	Page     int
+}
+
+type UserListResult struct {
+	Users      []User `json:"users"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"total_pages"`
+}
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserDisabled = errors.New("user is disabled")
+	ErrEmailTaken   = errors.New("email is already in use")
+	ErrInvalidLogin = errors.New("invalid email or password")
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// checkDummyPassword spends the same bcrypt work as a real comparison so
+// unknown emails cannot be told apart by response time.
+func checkDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = utils.HashPassword("not-a-real-password")
+	})
+	utils.CheckPasswordHash(password, dummyHash)
+}
+
+func maxLoginFailures() int {
+	return config.GetEnvInt("LOGIN_MAX_FAILURES", 5)
+}
+
+func loginLockout() time.Duration {
+	return time.Duration(config.GetEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+// ValidateCredentials checks the password and maintains the account's
+// failed-attempt counter, locking it once LOGIN_MAX_FAILURES is reached.
+// Locked accounts fail exactly like unknown emails, with the same bcrypt
+// work, so neither the response nor its timing reveals that they exist. A
+// password reset lifts the lock.
 func (u *User) ValidateCredentials() error {
 	query := `
-		SELECT id, password, role FROM users WHERE email = $1
+		SELECT id, password, role, disabled, totp_enabled, token_version, locked_until FROM users WHERE email = $1
 	`
 
 	row := db.DB.QueryRow(query, strings.ToLower(u.Email))
 
 	var retrivedPassword string
-	err := row.Scan(&u.ID, &retrivedPassword, &u.Role)
+	err := row.Scan(&u.ID, &retrivedPassword, &u.Role, &u.Disabled, &u.MFA, &u.TokenVersion, &u.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		checkDummyPassword(u.Password)
+		return ErrInvalidLogin
+	}
 	if err != nil {
 		return err
 	}
 
+	// Users provisioned through single sign-on have no local password.
+	if retrivedPassword == "" {
+		checkDummyPassword(u.Password)
+		return ErrInvalidLogin
+	}
+
+	if u.LockedUntil != nil && u.LockedUntil.After(time.Now()) {
+		checkDummyPassword(u.Password)
+		return ErrInvalidLogin
+	}
+
 	isValidPassword := utils.CheckPasswordHash(u.Password, retrivedPassword)
 
 	if !isValidPassword {
-		return errors.New("invalid email or password")
+		return u.recordFailedLogin()
+	}
+
+	_, err = db.DB.Exec(`UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1`, u.ID)
+	if err != nil {
+		return err
+	}
+
+	if u.Disabled {
+		return ErrUserDisabled
+	}
+	return nil
+}
+
+func (u *User) recordFailedLogin() error {
+	query := `
+		UPDATE users SET
+			failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $2 THEN 0 ELSE failed_login_attempts + 1 END,
+			locked_until = CASE WHEN failed_login_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE id = $1
+		RETURNING locked_until
+	`
+
+	err := db.DB.QueryRow(query, u.ID, maxLoginFailures(), time.Now().Add(loginLockout())).Scan(&u.LockedUntil)
+	if err != nil {
+		return err
+	}
+	return ErrInvalidLogin
+}
+
+func (u *User) Unlock() error {
+	result, err := db.DB.Exec(`UPDATE users SET failed_login_attempts = 0, locked_until = NULL WHERE id = $1`, u.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
 	}
 	return nil
 }
 
 func (u *User) Save() error {
+	return u.save(db.DB)
+}
+
+func (u *User) save(q rowQuerier) error {
 	if u.Role == "" {
 		u.Role = "staff"
 	}
@@ -54,20 +186,47 @@ func (u *User) Save() error {
 		return err
 	}
 
-	query := `INSERT INTO users(email,password,role) VALUES($1,$2,$3) RETURNING id`
+	query := `INSERT INTO users(email,name,password,role) VALUES($1,$2,$3,$4) RETURNING id`
+
+	err = q.QueryRow(query, strings.ToLower(u.Email), strings.TrimSpace(u.Name), hashedPassword, u.Role).Scan(&u.ID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailTaken
+	}
+	return err
+}
 
-	err = db.DB.QueryRow(query, strings.ToLower(u.Email), hashedPassword, u.Role).Scan(&u.ID)
+// BootstrapAdmin creates the first admin on a fresh install, where
+// invite-only registration leaves no other way to get one. It does nothing
+// once any admin exists and reports whether it created the account.
+func BootstrapAdmin(email, password string) (bool, error) {
+	if len(password) < 8 {
+		return false, errors.New("password must be at least 8 characters")
+	}
 
+	tx, err := db.DB.Begin()
 	if err != nil {
-		return err
+		return false, err
 	}
+	defer tx.Rollback()
 
-	return err
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE role = 'admin')`).Scan(&exists)
+	if err != nil || exists {
+		return false, err
+	}
+
+	user := User{Email: email, Password: password, Role: "admin"}
+	if err := user.save(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
 }
 
 func GetAllStaff() ([]User, error) {
 	query := `
-		SELECT id, email, role FROM users WHERE role = 'staff' ORDER BY email
+		SELECT id, email, name, role, disabled, totp_enabled FROM users WHERE role = 'staff' ORDER BY email
 	`
 
 	rows, err := db.DB.Query(query)
@@ -79,7 +238,7 @@ func GetAllStaff() ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var user User
-		err := rows.Scan(&user.ID, &user.Email, &user.Role)
+		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Disabled, &user.MFA)
 		if err != nil {
 			return nil, err
 		}
@@ -93,13 +252,84 @@ func GetAllStaff() ([]User, error) {
 	return users, nil
 }
 
+func GetUsers(filter UserFilter) (*UserListResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	var args []any
+	whereClause := ""
+
+	if filter.Search != "" {
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(filter.Search))+"%")
+		whereClause += fmt.Sprintf(" AND (email LIKE $%d OR LOWER(name) LIKE $%d)", len(args), len(args))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		whereClause += fmt.Sprintf(" AND role = $%d", len(args))
+	}
+	if filter.Disabled != nil {
+		args = append(args, *filter.Disabled)
+		whereClause += fmt.Sprintf(" AND disabled = $%d", len(args))
+	}
+
+	var total int
+	err := db.DB.QueryRow(`SELECT COUNT(*) FROM users WHERE 1=1`+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, email, name, role, disabled, totp_enabled FROM users WHERE 1=1` + whereClause
+	query += fmt.Sprintf(" ORDER BY email, id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Disabled, &user.MFA)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	totalPages := (total + filter.Limit - 1) / filter.Limit
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	return &UserListResult{
+		Users:      users,
+		Total:      total,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		TotalPages: totalPages,
+	}, nil
+}
+
 func (u *User) Get() error {
 	query := `
-		SELECT id, email, role FROM users WHERE id = $1
+		SELECT id, email, name, role, disabled, totp_enabled, locked_until FROM users WHERE id = $1
 	`
 
 	row := db.DB.QueryRow(query, u.ID)
-	err := row.Scan(&u.ID, &u.Email, &u.Role)
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.Disabled, &u.MFA, &u.LockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
 	if err != nil {
 		return err
 	}
@@ -107,23 +337,52 @@ func (u *User) Get() error {
 	return nil
 }
 
+func CheckUserPassword(userID, password string) (bool, error) {
+	var hash string
+	err := db.DB.QueryRow(`SELECT password FROM users WHERE id = $1`, userID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	return utils.CheckPasswordHash(password, hash), nil
+}
+
+func GetTokenVersion(userID string) (int, error) {
+	var version int
+	err := db.DB.QueryRow(`SELECT token_version FROM users WHERE id = $1 AND NOT disabled`, userID).Scan(&version)
+	return version, err
+}
+
 func (u *UserUpdate) Update() error {
-	var exists bool
-	err := db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", u.ID).Scan(&exists)
+	var currentEmail string
+	err := db.DB.QueryRow("SELECT email FROM users WHERE id = $1", u.ID).Scan(&currentEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return errors.New("user not found")
-	}
 
 	query := "UPDATE users SET "
 	args := []interface{}{}
 	argIndex := 1
 
-	if u.Email != "" {
+	// Changing credentials, role or access revokes the user's tokens.
+	revoke := false
+
+	// Tokens carry the email, and it is what the user signs in with.
+	if u.Email != "" && strings.ToLower(u.Email) != currentEmail {
 		query += "email = $" + fmt.Sprintf("%d", argIndex) + ", "
-		args = append(args, u.Email)
+		args = append(args, strings.ToLower(u.Email))
+		argIndex++
+		revoke = true
+	}
+
+	if u.Name != nil {
+		query += "name = $" + fmt.Sprintf("%d", argIndex) + ", "
+		args = append(args, strings.TrimSpace(*u.Name))
 		argIndex++
 	}
 
@@ -135,12 +394,29 @@ func (u *UserUpdate) Update() error {
 		query += "password = $" + fmt.Sprintf("%d", argIndex) + ", "
 		args = append(args, hashedPassword)
 		argIndex++
+		revoke = true
 	}
 
 	if u.Role != "" {
 		query += "role = $" + fmt.Sprintf("%d", argIndex) + ", "
 		args = append(args, u.Role)
 		argIndex++
+		revoke = true
+	}
+
+	if u.Disabled != nil {
+		query += "disabled = $" + fmt.Sprintf("%d", argIndex) + ", "
+		args = append(args, *u.Disabled)
+		argIndex++
+		revoke = true
+	}
+
+	if revoke {
+		query += "token_version = token_version + 1, "
+	}
+
+	if len(args) == 0 {
+		return nil
 	}
 
 	query = query[:len(query)-2]
@@ -148,7 +424,21 @@ func (u *UserUpdate) Update() error {
 	args = append(args, u.ID)
 
 	_, err = db.DB.Exec(query, args...)
-	return err
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	u.revoked = revoke
+	if revoke {
+		if err := notifyRevocation(db.DB, u.ID); err != nil {
+			log.Printf("❌ Failed to announce token revocation for user %s: %v", u.ID, err)
+		}
+	}
+	return nil
 }
 
 func (u *User) Delete() error {
@@ -164,7 +454,7 @@ func (u *User) Delete() error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	return nil
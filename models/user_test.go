@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"stock-dashboard/utils"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var credentialColumns = []string{"id", "password", "role", "disabled", "totp_enabled", "token_version", "locked_until"}
+
+func TestValidateCredentialsHidesLockedAccounts(t *testing.T) {
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockedUntil := time.Now().Add(10 * time.Minute)
+
+	tests := []struct {
+		name     string
+		rows     *sqlmock.Rows
+		password string
+	}{
+		{"unknown email", sqlmock.NewRows(credentialColumns), "anything"},
+		{"locked, wrong password", sqlmock.NewRows(credentialColumns).
+			AddRow("1", hash, "admin", false, false, 0, lockedUntil), "wrong"},
+		{"locked, right password", sqlmock.NewRows(credentialColumns).
+			AddRow("1", hash, "admin", false, false, 0, lockedUntil), "correct horse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery(`SELECT id, password`).WithArgs("admin@example.com").WillReturnRows(tt.rows)
+
+			user := User{Email: "Admin@example.com", Password: tt.password}
+			if err := user.ValidateCredentials(); !errors.Is(err, ErrInvalidLogin) {
+				t.Errorf("ValidateCredentials() = %v, want ErrInvalidLogin", err)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsLocksAfterFailures(t *testing.T) {
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := mockDB(t)
+
+	mock.ExpectQuery(`SELECT id, password`).
+		WillReturnRows(sqlmock.NewRows(credentialColumns).AddRow("1", hash, "staff", false, false, 0, nil))
+	mock.ExpectQuery(`UPDATE users SET\s+failed_login_attempts`).
+		WithArgs("1", 5, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(time.Now().Add(15 * time.Minute)))
+
+	// The attempt that trips the lock looks like any other failure.
+	user := User{Email: "staff@example.com", Password: "wrong"}
+	if err := user.ValidateCredentials(); !errors.Is(err, ErrInvalidLogin) {
+		t.Errorf("ValidateCredentials() = %v, want ErrInvalidLogin", err)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminExists bool
+		want        bool
+	}{
+		{"fresh install", false, true},
+		{"admin already exists", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE role = 'admin'\)`).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.adminExists))
+			if tt.want {
+				mock.ExpectQuery(`INSERT INTO users`).
+					WithArgs("admin@example.com", "", sqlmock.AnyArg(), "admin").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			created, err := BootstrapAdmin("Admin@example.com", "correct horse")
+			if err != nil || created != tt.want {
+				t.Errorf("BootstrapAdmin() = %v, %v, want %v", created, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("short password", func(t *testing.T) {
+		mockDB(t)
+		if _, err := BootstrapAdmin("admin@example.com", "short"); err == nil {
+			t.Error("BootstrapAdmin() accepted a short password")
+		}
+	})
+}
+
+func TestUserUpdateEmailRevokesTokens(t *testing.T) {
+	name := "Sam"
+	tests := []struct {
+		name        string
+		update      UserUpdate
+		wantQuery   string
+		wantRevoked bool
+	}{
+		{"new email", UserUpdate{ID: "1", Email: "New@example.com"},
+			`UPDATE users SET email = \$1, token_version = token_version \+ 1 WHERE id = \$2`, true},
+		{"same email", UserUpdate{ID: "1", Email: "Old@example.com", Name: &name},
+			`UPDATE users SET name = \$1 WHERE id = \$2`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery(`SELECT email FROM users`).WithArgs("1").
+				WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("old@example.com"))
+			mock.ExpectExec(tt.wantQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+			if tt.wantRevoked {
+				mock.ExpectExec(`pg_notify`).WithArgs(sessionChannel, "1").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			if err := tt.update.Update(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.update.Revoked() != tt.wantRevoked {
+				t.Errorf("Revoked() = %v, want %v", tt.update.Revoked(), tt.wantRevoked)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"stock-dashboard/db"
+	"time"
+)
+
+var ErrViewNotFound = errors.New("view not found")
+
+var savedViewColumns = map[string]bool{
+	"id": true, "name": true, "price": true, "stock": true, "category": true,
+	"value": true, "abcClass": true, "xyzClass": true, "createdAt": true, "updatedAt": true,
+}
+
+type SavedView struct {
+	ID        int64         `json:"id"`
+	UserID    string        `json:"userId"`
+	Name      string        `json:"name" binding:"required,max=100"`
+	Filter    ProductFilter `json:"filter"`
+	Sort      string        `json:"sort"`
+	Columns   []string      `json:"columns"`
+	Shared    bool          `json:"shared"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+func (v *SavedView) Validate() error {
+	if _, err := ParseProductSort(v.Sort); err != nil {
+		return err
+	}
+	if _, err := ParseProductSort(v.Filter.Sort); err != nil {
+		return err
+	}
+	for _, column := range v.Columns {
+		if !savedViewColumns[column] {
+			return errors.New("unsupported column " + column)
+		}
+	}
+	return nil
+}
+
+func (v *SavedView) scan(row rowScanner) error {
+	var filter, columns []byte
+	err := row.Scan(&v.ID, &v.UserID, &v.Name, &filter, &v.Sort, &columns, &v.Shared, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(filter, &v.Filter); err != nil {
+		return err
+	}
+	return json.Unmarshal(columns, &v.Columns)
+}
+
+func (v *SavedView) encode() ([]byte, []byte, error) {
+	// Pagination belongs to the request, not the view.
+	v.Filter.Offset = 0
+	if v.Columns == nil {
+		v.Columns = []string{}
+	}
+
+	filter, err := json.Marshal(v.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, err := json.Marshal(v.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, columns, nil
+}
+
+func (v *SavedView) Save() error {
+	filter, columns, err := v.encode()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO saved_views (user_id, name, filter, sort, columns, shared, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	now := time.Now()
+	v.CreatedAt = now
+	v.UpdatedAt = now
+
+	return db.DB.QueryRow(query, v.UserID, v.Name, filter, v.Sort, columns, v.Shared, v.CreatedAt, v.UpdatedAt).Scan(&v.ID)
+}
+
+// Get loads the view if it belongs to userID or is shared with the team.
+func (v *SavedView) Get(userID string) error {
+	query := `
+		SELECT id, user_id, name, filter, sort, columns, shared, created_at, updated_at
+		FROM saved_views
+		WHERE id = $1 AND (user_id = $2 OR shared = TRUE)
+	`
+
+	err := v.scan(db.DB.QueryRow(query, v.ID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrViewNotFound
+	}
+	return err
+}
+
+func (v *SavedView) Update() error {
+	filter, columns, err := v.encode()
+	if err != nil {
+		return err
+	}
+
+	v.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE saved_views
+		SET name = $1, filter = $2, sort = $3, columns = $4, shared = $5, updated_at = $6
+		WHERE id = $7 AND user_id = $8
+		RETURNING created_at
+	`
+
+	err = db.DB.QueryRow(query, v.Name, filter, v.Sort, columns, v.Shared, v.UpdatedAt, v.ID, v.UserID).Scan(&v.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrViewNotFound
+	}
+	return err
+}
+
+func (v *SavedView) Delete() error {
+	result, err := db.DB.Exec(`DELETE FROM saved_views WHERE id = $1 AND user_id = $2`, v.ID, v.UserID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrViewNotFound
+	}
+
+	return nil
+}
+
+func GetSavedViews(userID string) ([]SavedView, error) {
+	query := `
+		SELECT id, user_id, name, filter, sort, columns, shared, created_at, updated_at
+		FROM saved_views
+		WHERE user_id = $1 OR shared = TRUE
+		ORDER BY name
+	`
+
+	rows, err := db.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := []SavedView{}
+	for rows.Next() {
+		var view SavedView
+		err := view.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
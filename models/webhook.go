@@ -0,0 +1,497 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"stock-dashboard/db"
+	"stock-dashboard/events"
+	"stock-dashboard/utils"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+
+	maxDeliveryAttempts = 8
+	webhookTimeout      = 10 * time.Second
+	deliveryBatchSize   = 20
+	deliveryConcurrency = 5
+
+	// deliveryLease has to outlast a whole batch, or another instance could
+	// claim deliveries that are still being sent and post them twice.
+	deliveryLease = webhookTimeout*deliveryBatchSize/deliveryConcurrency + time.Minute
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+)
+
+var ErrBlockedWebhookAddress = errors.New("webhook address is not publicly routable")
+
+// allowPrivateWebhookTargets lets tests deliver to receivers on loopback.
+var allowPrivateWebhookTargets bool
+
+// Carrier-grade NAT space hosts some cloud metadata services (for example
+// 100.100.100.200), so it is refused along with the private ranges.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// blockedWebhookIP reports whether ip is somewhere a webhook must not reach:
+// loopback, private, link-local (which covers the 169.254.169.254 metadata
+// endpoint), unspecified or multicast.
+func blockedWebhookIP(ip netip.Addr) bool {
+	if allowPrivateWebhookTargets {
+		return false
+	}
+	ip = ip.Unmap()
+	return !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// The address is checked again when connecting, because DNS can point a
+// saved hostname somewhere else after Validate ran. Proxies are not used, as
+// they would make the dialed address the proxy's instead of the receiver's.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				addrPort, err := netip.ParseAddrPort(address)
+				if err != nil {
+					return err
+				}
+				if blockedWebhookIP(addrPort.Addr()) {
+					return fmt.Errorf("%w: %s", ErrBlockedWebhookAddress, addrPort.Addr())
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   webhookTimeout,
+		ResponseHeaderTimeout: webhookTimeout,
+		MaxIdleConns:          deliveryConcurrency,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url" binding:"required"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events" binding:"required,min=1"`
+	Active    bool      `json:"active"`
+	CreatedBy *string   `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhookId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt"`
+	LastStatusCode *int            `json:"lastStatusCode"`
+	LastError      *string         `json:"lastError"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`
+}
+
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+	if err := checkWebhookHost(u.Hostname()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	for _, eventType := range w.Events {
+		if eventType != "*" && !events.IsValidType(eventType) {
+			return fmt.Errorf("%w: unsupported event type %q", ErrInvalidWebhook, eventType)
+		}
+	}
+	return nil
+}
+
+func checkWebhookHost(host string) error {
+	ips := []netip.Addr{}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ips = append(ips, ip)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ips, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return fmt.Errorf("cannot resolve %s", host)
+		}
+	}
+
+	for _, ip := range ips {
+		if blockedWebhookIP(ip) {
+			return fmt.Errorf("%s resolves to %s, which is not publicly routable", host, ip.Unmap())
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+const webhookColumns = `id, url, events, active, created_by::text, created_at, updated_at`
+
+func (w *Webhook) scan(row rowScanner) error {
+	return row.Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Active, &w.CreatedBy, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func (w *Webhook) Save() error {
+	if w.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+
+	query := `
+		INSERT INTO webhooks (url, secret, events, active, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	now := time.Now()
+	w.CreatedAt = now
+	w.UpdatedAt = now
+
+	return db.DB.QueryRow(query, w.URL, w.Secret, pq.Array(w.Events), w.Active, w.CreatedBy, now, now).Scan(&w.ID)
+}
+
+func (w *Webhook) Get() error {
+	err := w.scan(db.DB.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, w.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+func (w *Webhook) Update() error {
+	w.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE webhooks SET url = $1, events = $2, active = $3, updated_at = $4,
+			secret = COALESCE(NULLIF($5, ''), secret)
+		WHERE id = $6
+		RETURNING ` + webhookColumns
+
+	err := w.scan(db.DB.QueryRow(query, w.URL, pq.Array(w.Events), w.Active, w.UpdatedAt, w.Secret, w.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+func (w *Webhook) Delete() error {
+	result, err := db.DB.Exec(`DELETE FROM webhooks WHERE id = $1`, w.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func GetWebhooks() ([]Webhook, error) {
+	rows, err := db.DB.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := webhook.scan(rows); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func GetWebhookDeliveries(webhookID int64, status string, limit int) ([]WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+			last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`
+
+	rows, err := db.DB.Query(query, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver puts a delivery back on the queue for an immediate attempt,
+// regardless of whether it previously succeeded or gave up.
+func RedeliverWebhook(webhookID, deliveryID int64) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = 0, next_attempt_at = $2, delivered_at = NULL
+		WHERE id = $3 AND webhook_id = $4
+	`
+
+	result, err := db.DB.Exec(query, DeliveryPending, time.Now(), deliveryID, webhookID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+// enqueueWebhookDeliveries queues one delivery per active webhook subscribed
+// to the event's type.
+func enqueueWebhookDeliveries(tx *sql.Tx, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT id, $1, $2, $3, $4, $4
+		FROM webhooks
+		WHERE active = TRUE AND ($1 = ANY(events) OR '*' = ANY(events))
+	`
+
+	_, err = tx.Exec(query, event.Type, payload, DeliveryPending, event.CreatedAt)
+	return err
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second * time.Duration(math.Pow(2, float64(attempts-1)))
+	if backoff > 6*time.Hour {
+		backoff = 6 * time.Hour
+	}
+	return backoff
+}
+
+type claimedDelivery struct {
+	id        int64
+	eventType string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// claimWebhookDeliveries leases due deliveries by pushing their next attempt
+// into the future, so other instances skip them while they are in flight.
+func claimWebhookDeliveries() ([]claimedDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $1
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.event_type, d.payload, d.attempts, w.url, w.secret
+	`
+
+	now := time.Now()
+	rows, err := db.DB.Query(query, now.Add(deliveryLease), DeliveryPending, now, deliveryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []claimedDelivery
+	for rows.Next() {
+		var d claimedDelivery
+		if err := rows.Scan(&d.id, &d.eventType, &d.payload, &d.attempts, &d.url, &d.secret); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, d)
+	}
+
+	return claimed, rows.Err()
+}
+
+func sendWebhook(d claimedDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "stock-dashboard-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", d.eventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+utils.SignWebhookPayload(d.secret, timestamp, d.payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func recordWebhookAttempt(d claimedDelivery, statusCode int, sendErr error) error {
+	attempts := d.attempts + 1
+	now := time.Now()
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	if sendErr == nil {
+		_, err := db.DB.Exec(`
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, last_status_code = $3, last_error = NULL,
+				delivered_at = $4, next_attempt_at = NULL
+			WHERE id = $5
+		`, DeliverySucceeded, attempts, code, now, d.id)
+		return err
+	}
+
+	status := DeliveryPending
+	var next *time.Time
+	if attempts >= maxDeliveryAttempts {
+		status = DeliveryFailed
+	} else {
+		retryAt := now.Add(webhookBackoff(attempts))
+		next = &retryAt
+	}
+
+	_, err := db.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5
+		WHERE id = $6
+	`, status, attempts, code, sendErr.Error(), next, d.id)
+	return err
+}
+
+func dispatchWebhooks() {
+	claimed, err := claimWebhookDeliveries()
+	if err != nil {
+		log.Printf("❌ Failed to claim webhook deliveries: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, deliveryConcurrency)
+	for _, d := range claimed {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(d claimedDelivery) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			statusCode, sendErr := sendWebhook(d)
+			if err := recordWebhookAttempt(d, statusCode, sendErr); err != nil {
+				log.Printf("❌ Failed to record webhook delivery %d: %v", d.id, err)
+			}
+		}(d)
+	}
+	wg.Wait()
+}
+
+func StartWebhookDispatcher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			dispatchWebhooks()
+		}
+	}()
+}
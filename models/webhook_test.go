@@ -0,0 +1,269 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"stock-dashboard/db"
+	"stock-dashboard/utils"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = previous
+		conn.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return mock
+}
+
+// around matches a time argument within a second of the expected one.
+type around struct{ want time.Time }
+
+func (a around) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	diff := got.Sub(a.want)
+	return diff > -time.Second && diff < time.Second
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{12, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryLeaseOutlastsBatch(t *testing.T) {
+	rounds := (deliveryBatchSize + deliveryConcurrency - 1) / deliveryConcurrency
+	if worst := webhookTimeout * time.Duration(rounds); deliveryLease <= worst {
+		t.Errorf("deliveryLease %v does not outlast a batch (%v)", deliveryLease, worst)
+	}
+}
+
+// allowLoopbackReceivers lets a test deliver to an httptest server.
+func allowLoopbackReceivers(t *testing.T) {
+	t.Helper()
+	allowPrivateWebhookTargets = true
+	t.Cleanup(func() { allowPrivateWebhookTargets = false })
+}
+
+func TestWebhookValidateRejectsInternalAddresses(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://93.184.215.14/hook", false},
+		{"https://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]/hook", false},
+		{"http://127.0.0.1:8080/hook", true},
+		{"http://localhost/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://10.0.0.5/hook", true},
+		{"http://172.16.3.4/hook", true},
+		{"http://192.168.1.1/hook", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://100.100.100.200/hook", true},
+		{"http://[fd00:ec2::254]/hook", true},
+		{"http://[fe80::1]/hook", true},
+		{"http://[::ffff:127.0.0.1]/hook", true},
+		{"http://0.0.0.0/hook", true},
+		{"http://224.0.0.1/hook", true},
+		{"ftp://93.184.215.14/hook", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			w := Webhook{URL: tt.url, Events: []string{"*"}}
+			err := w.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidWebhook) {
+				t.Errorf("Validate() error = %v, want ErrInvalidWebhook", err)
+			}
+		})
+	}
+}
+
+func TestSendWebhookRefusesInternalAddresses(t *testing.T) {
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer receiver.Close()
+
+	// A URL that passed Validate can still resolve to a blocked address by
+	// the time it is delivered, so the dialer checks again.
+	d := claimedDelivery{id: 1, eventType: "product.created", payload: []byte(`{}`), url: receiver.URL, secret: "s"}
+	status, err := sendWebhook(d)
+	if !errors.Is(err, ErrBlockedWebhookAddress) || status != 0 {
+		t.Errorf("sendWebhook() = %d, %v; want 0, ErrBlockedWebhookAddress", status, err)
+	}
+	if received.Load() != 0 {
+		t.Error("receiver on loopback was contacted")
+	}
+}
+
+func TestSendWebhookSignsPayload(t *testing.T) {
+	allowLoopbackReceivers(t)
+	payload := []byte(`{"type":"product.created"}`)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Webhook-Timestamp")
+		if !utils.VerifyWebhookSignature("whsec_test", timestamp, body, r.Header.Get("X-Webhook-Signature")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Webhook-Event") != "product.created" || r.Header.Get("X-Webhook-Delivery") != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := claimedDelivery{id: 7, eventType: "product.created", payload: payload, url: receiver.URL, secret: "whsec_test"}
+	status, err := sendWebhook(d)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("sendWebhook() = %d, %v; want 204, nil", status, err)
+	}
+
+	d.secret = "whsec_other"
+	status, err = sendWebhook(d)
+	if err == nil || status != http.StatusUnauthorized {
+		t.Fatalf("sendWebhook() with wrong secret = %d, %v; want 401 and an error", status, err)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	payload := []byte(`{"id":1}`)
+	signature := "sha256=" + utils.SignWebhookPayload("secret", "1700000000", payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", "1700000000", payload, signature, true},
+		{"wrong secret", "other", "1700000000", payload, signature, false},
+		{"replayed timestamp", "secret", "1700000001", payload, signature, false},
+		{"tampered payload", "secret", "1700000000", []byte(`{"id":2}`), signature, false},
+		{"missing prefix", "secret", "1700000000", payload, signature[len("sha256="):], false},
+	}
+
+	for _, tt := range tests {
+		if got := utils.VerifyWebhookSignature(tt.secret, tt.timestamp, tt.payload, tt.signature); got != tt.want {
+			t.Errorf("%s: VerifyWebhookSignature() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordWebhookAttemptSchedulesRetry(t *testing.T) {
+	mock := mockDB(t)
+
+	d := claimedDelivery{id: 3, attempts: 1}
+	mock.ExpectExec(`UPDATE webhook_deliveries`).
+		WithArgs(DeliveryPending, 2, 500, "receiver responded with 500", around{time.Now().Add(time.Minute)}, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := recordWebhookAttempt(d, 500, errors.New("receiver responded with 500")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordWebhookAttemptGivesUp(t *testing.T) {
+	mock := mockDB(t)
+
+	d := claimedDelivery{id: 3, attempts: maxDeliveryAttempts - 1}
+	mock.ExpectExec(`UPDATE webhook_deliveries`).
+		WithArgs(DeliveryFailed, maxDeliveryAttempts, nil, "timeout", nil, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := recordWebhookAttempt(d, 0, errors.New("timeout")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	mock := mockDB(t)
+
+	mock.ExpectExec(`UPDATE webhook_deliveries\s+SET status = \$1, attempts = 0`).
+		WithArgs(DeliveryPending, around{time.Now()}, int64(9), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE webhook_deliveries`).
+		WithArgs(DeliveryPending, sqlmock.AnyArg(), int64(10), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := RedeliverWebhook(2, 9); err != nil {
+		t.Errorf("RedeliverWebhook(2, 9) = %v, want nil", err)
+	}
+	if err := RedeliverWebhook(2, 10); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("RedeliverWebhook(2, 10) = %v, want ErrDeliveryNotFound", err)
+	}
+}
+
+func TestDispatchWebhooks(t *testing.T) {
+	mock := mockDB(t)
+	mock.MatchExpectationsInOrder(false)
+	allowLoopbackReceivers(t)
+
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`UPDATE webhook_deliveries d\s+SET next_attempt_at = \$1`).
+		WithArgs(around{now.Add(deliveryLease)}, DeliveryPending, around{now}, deliveryBatchSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "payload", "attempts", "url", "secret"}).
+			AddRow(int64(1), "product.created", []byte(`{}`), 0, receiver.URL, "s").
+			AddRow(int64(2), "stock.low", []byte(`{}`), 3, receiver.URL, "s"))
+	for _, id := range []int64{1, 2} {
+		mock.ExpectExec(`UPDATE webhook_deliveries`).
+			WithArgs(DeliverySucceeded, sqlmock.AnyArg(), http.StatusOK, sqlmock.AnyArg(), id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	dispatchWebhooks()
+
+	if received.Load() != 2 {
+		t.Errorf("receiver got %d deliveries, want 2", received.Load())
+	}
+}
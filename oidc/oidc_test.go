@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is a minimal OIDC provider. Its authorize endpoint is never
+// hit over HTTP: tests pass the URL from AuthCodeURL to authorize, which
+// remembers the PKCE challenge and nonce and returns a one-time code.
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	codes  map[string]url.Values
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key, codes: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{{
+			Kid: "k1",
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) authorize(authURL string) string {
+	u, _ := url.Parse(authURL)
+	code := "code-" + u.Query().Get("state")
+	m.codes[code] = u.Query()
+	return code
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	request, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if request.Get("code_challenge_method") != "S256" ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != request.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": m.idToken(request.Get("client_id"), request.Get("nonce"))})
+}
+
+func (m *mockProvider) idToken(audience, nonce string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   m.server.URL,
+		"sub":   "user-1",
+		"aud":   audience,
+		"nonce": nonce,
+		"email": "user@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+	})
+	token.Header["kid"] = "k1"
+	signed, _ := token.SignedString(m.key)
+	return signed
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+	provider, err := Discover(context.Background(), Config{Issuer: m.server.URL, ClientID: "dashboard"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("verifier-1"))
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "dashboard",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+		"scope":                 "openid email profile",
+	}
+	for param, value := range want {
+		if got := u.Query().Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+	if u.Query().Has("code_verifier") {
+		t.Error("authorization URL leaks the PKCE verifier")
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	if _, err := Discover(context.Background(), Config{Issuer: m.server.URL + "/other"}); err == nil {
+		t.Error("Discover() accepted a provider reporting a different issuer")
+	}
+}
+
+func TestLoginFlow(t *testing.T) {
+	tests := []struct {
+		name         string
+		verifier     string
+		nonce        string
+		wantExchange bool
+		wantErr      error
+	}{
+		{"valid", "verifier-1", "nonce-1", true, nil},
+		{"wrong PKCE verifier", "verifier-2", "nonce-1", false, nil},
+		{"wrong nonce", "verifier-1", "nonce-2", true, ErrInvalidIDToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newMockProvider(t)
+			provider, err := Discover(ctx, Config{Issuer: m.server.URL, ClientID: "dashboard"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code := m.authorize(provider.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+			idToken, err := provider.Exchange(ctx, code, tt.verifier)
+			if (err == nil) != tt.wantExchange {
+				t.Fatalf("Exchange() error = %v, want success %v", err, tt.wantExchange)
+			}
+			if err != nil {
+				return
+			}
+
+			claims, err := provider.VerifyIDToken(ctx, idToken, tt.nonce)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyIDToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims["sub"] != "user-1" {
+				t.Errorf("sub = %v, want user-1", claims["sub"])
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	ctx := context.Background()
+	m := newMockProvider(t)
+	provider, err := Discover(ctx, Config{Issuer: m.server.URL, ClientID: "dashboard"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := m.authorize(provider.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	if _, err := provider.Exchange(ctx, code, "verifier-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(ctx, code, "verifier-1"); err == nil {
+		t.Error("Exchange() redeemed the same code twice")
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignTokens(t *testing.T) {
+	ctx := context.Background()
+	m := newMockProvider(t)
+	provider, err := Discover(ctx, Config{Issuer: m.server.URL, ClientID: "dashboard"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := &mockProvider{server: m.server, key: other}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"other audience", m.idToken("someone-else", "nonce-1")},
+		{"forged signature", forged.idToken("dashboard", "nonce-1")},
+		{"unsigned", func() string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+				"iss": m.server.URL, "aud": "dashboard", "nonce": "nonce-1", "exp": time.Now().Add(time.Hour).Unix(),
+			}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return s
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.VerifyIDToken(ctx, tt.token, "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("VerifyIDToken() error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"stock-dashboard/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func parseAPIKeyID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid API key ID")
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+	return id, true
+}
+
+func apiKeyNotFoundOr(c *gin.Context, err error, message string) {
+	if errors.Is(err, models.ErrAPIKeyNotFound) {
+		response := models.NewErrorResponse("API key not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	response := models.NewErrorResponse(message)
+	c.JSON(http.StatusInternalServerError, response)
+}
+
+func GetAPIKeys(c *gin.Context) {
+	keys, err := models.GetAPIKeys()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch API keys")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := models.NewSuccessResponse(keys, "API keys fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetAPIKey(c *gin.Context) {
+	id, ok := parseAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	key := models.APIKey{ID: id}
+	err := key.Get()
+	if err != nil {
+		apiKeyNotFoundOr(c, err, "Failed to fetch API key")
+		return
+	}
+
+	response := models.NewSuccessResponse(key, "API key fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateAPIKey(c *gin.Context) {
+	var key models.APIKey
+	err := c.ShouldBindJSON(&key)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request data: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = key.Validate()
+	if err != nil {
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	createdBy := c.GetString("userID")
+	key.CreatedBy = &createdBy
+	secret, err := key.Save()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to create API key")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditCreate, "api_key", strconv.FormatInt(key.ID, 10), nil, key)
+
+	// The full key is only ever returned here, when it is created.
+	data := gin.H{
+		"apiKey": key,
+		"key":    secret,
+	}
+	response := models.NewSuccessResponse(data, "API key created successfully")
+	c.JSON(http.StatusCreated, response)
+}
+
+func UpdateAPIKey(c *gin.Context) {
+	id, ok := parseAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	var key models.APIKey
+	err := c.ShouldBindJSON(&key)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request data: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = key.Validate()
+	if err != nil {
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	before := models.APIKey{ID: id}
+	err = before.Get()
+	if err != nil {
+		apiKeyNotFoundOr(c, err, "Failed to update API key")
+		return
+	}
+
+	key.ID = id
+	err = key.Update()
+	if err != nil {
+		apiKeyNotFoundOr(c, err, "Failed to update API key")
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "api_key", c.Param("id"), before, key)
+
+	response := models.NewSuccessResponse(key, "API key updated successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	id, ok := parseAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	key := models.APIKey{ID: id}
+	err := key.Revoke()
+	if err != nil {
+		apiKeyNotFoundOr(c, err, "Failed to revoke API key")
+		return
+	}
+
+	recordAudit(c, AuditRevoke, "api_key", c.Param("id"), nil, key)
+
+	response := models.NewSuccessResponse(key, "API key revoked successfully")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"stock-dashboard/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditRevoke  = "revoke"
+	AuditUnlock  = "unlock"
+	// AuditRedeliver and AuditRecalculate record actions that re-run work
+	// rather than change a single record.
+	AuditRedeliver   = "redeliver"
+	AuditRecalculate = "recalculate"
+)
+
+// recordAudit stores an audit entry for the current request. A failure to
+// audit is logged but never fails a write that has already been committed.
+func recordAudit(c *gin.Context, action, entity, entityID string, before, after any) {
+	err := models.RecordAudit(c.GetString("userID"), c.GetString("apiKeyID"), action, entity, entityID, before, after,
+		c.ClientIP(), c.GetString("requestID"))
+	if err != nil {
+		log.Printf("❌ Failed to record audit entry for %s %s %s: %v", action, entity, entityID, err)
+	}
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func GetAuditLogs(c *gin.Context) {
+	filter := models.AuditFilter{
+		ActorID:  c.Query("actor"),
+		Entity:   c.Query("entity"),
+		EntityID: c.Query("entity_id"),
+		Action:   c.Query("action"),
+	}
+
+	if _, err := strconv.Atoi(filter.ActorID); filter.ActorID != "" && err != nil {
+		response := models.NewErrorResponse("Invalid actor ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseAuditTime(from)
+		if err != nil {
+			response := models.NewErrorResponse("Invalid from date")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		filter.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseAuditTime(to)
+		if err != nil {
+			response := models.NewErrorResponse("Invalid to date")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = t
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 200 {
+		filter.Limit = limit
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+
+	result, err := models.GetAuditLogs(filter)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch audit logs")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"entries":    result.Entries,
+		"count":      len(result.Entries),
+		"page":       result.Page,
+		"limit":      result.Limit,
+		"total":      result.Total,
+		"totalPages": result.TotalPages,
+	}
+	response := models.NewSuccessResponse(data, "Audit logs fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"stock-dashboard/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseSuggestionTypes reads the types parameter. Types we have no data for,
+// such as sku, are skipped so clients can ask for them ahead of time; only a
+// list with nothing we support is rejected.
+func parseSuggestionTypes(raw string) ([]string, error) {
+	if raw == "" {
+		return []string{models.SuggestionProduct, models.SuggestionCategory}, nil
+	}
+
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(strings.ToLower(t))
+		if (t == models.SuggestionProduct || t == models.SuggestionCategory) && !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("unsupported suggestion types %q", raw)
+	}
+	return types, nil
+}
+
+func Autocomplete(c *gin.Context) {
+	term := strings.TrimSpace(c.Query("q"))
+	if term == "" {
+		response := models.NewErrorResponse("Query is required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	types, err := parseSuggestionTypes(c.Query("types"))
+	if err != nil {
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	limit := 5
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 20 {
+		limit = l
+	}
+
+	suggestions, err := models.Autocomplete(term, types, limit)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch suggestions")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+	}
+	response := models.NewSuccessResponse(data, "Suggestions fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
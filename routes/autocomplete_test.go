@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSuggestionTypes(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{"product", "category"}, false},
+		{"product,category,sku", []string{"product", "category"}, false},
+		{" Category , product", []string{"category", "product"}, false},
+		{"product,product", []string{"product"}, false},
+		{"sku", nil, true},
+		{",", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSuggestionTypes(tt.raw)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSuggestionTypes(%q) = %v, %v, want %v, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http"
+	"stock-dashboard/config"
+	"stock-dashboard/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func productETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		tag = strings.TrimPrefix(tag, "W/")
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func etagMatches(header string, version int) bool {
+	current := productETag(version)
+	for _, tag := range parseETags(header) {
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion reads If-Match for a write and returns the product version
+// the client last saw, or 0 when no check should be made. It writes the
+// error response itself and returns false if the request must stop.
+func expectedVersion(c *gin.Context) (int, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if config.GetEnvBool("ETAG_STRICT", false) {
+			response := models.NewErrorResponse("If-Match header is required")
+			c.JSON(http.StatusPreconditionRequired, response)
+			return 0, false
+		}
+		return 0, true
+	}
+
+	tags := parseETags(header)
+	if len(tags) == 1 && tags[0] == "*" {
+		return 0, true
+	}
+	if len(tags) != 1 {
+		response := models.NewErrorResponse("If-Match must contain a single ETag")
+		c.JSON(http.StatusBadRequest, response)
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.Trim(tags[0], `"`))
+	if err != nil || version <= 0 {
+		response := models.NewErrorResponse("Product has been modified")
+		c.JSON(http.StatusPreconditionFailed, response)
+		return 0, false
+	}
+
+	return version, true
+}
@@ -0,0 +1,170 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"stock-dashboard/events"
+	"stock-dashboard/middleware"
+	"stock-dashboard/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sseHeartbeatInterval = 15 * time.Second
+	sseAuthCheckInterval = 30 * time.Second
+)
+
+func parseEventFilter(c *gin.Context) (func(events.Event) bool, error) {
+	categories := make(map[string]bool)
+	for _, category := range strings.Split(c.Query("categories"), ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories[strings.ToLower(category)] = true
+		}
+	}
+
+	productIDs := make(map[int64]bool)
+	for _, raw := range strings.Split(c.Query("product_ids"), ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid product ID %q", raw)
+		}
+		productIDs[id] = true
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !events.IsValidType(t) {
+			return nil, fmt.Errorf("unsupported event type %q", t)
+		}
+		types[t] = true
+	}
+
+	return func(e events.Event) bool {
+		if len(types) > 0 && !types[e.Type] {
+			return false
+		}
+		if len(categories) > 0 && !categories[strings.ToLower(e.Category)] {
+			return false
+		}
+		if len(productIDs) > 0 && !productIDs[e.ProductID] {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func writeSSE(w io.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// streamAuthorized re-checks the stream's credentials. When they are no
+// longer valid it tells the client why before the stream is closed.
+func streamAuthorized(c *gin.Context) bool {
+	err := middleware.Reauthenticate(c)
+	if err == nil {
+		return true
+	}
+
+	data, _ := json.Marshal(gin.H{"message": err.Error()})
+	fmt.Fprintf(c.Writer, "event: unauthorized\ndata: %s\n\n", data)
+	c.Writer.Flush()
+	return false
+}
+
+func StreamEvents(c *gin.Context) {
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	lastID, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	stream, backlog, complete := events.OpenStream(lastID, filter)
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	// The client missed events that are no longer buffered, so it has to
+	// refetch its state rather than rely on the replay.
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		if writeSSE(w, event) != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	authCheck := time.NewTicker(sseAuthCheckInterval)
+	defer authCheck.Stop()
+
+	// API keys are not tied to sessions, so their streams only use the
+	// periodic check.
+	var revoked <-chan struct{}
+	if _, isAPIKey := c.Get("apiKey"); !isAPIKey {
+		var stop func()
+		revoked, stop = models.WatchRevocations(c.GetString("userID"))
+		defer stop()
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-stream.C:
+			if !ok {
+				return
+			}
+			if writeSSE(w, event) != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		case <-authCheck.C:
+			if !streamAuthorized(c) {
+				return
+			}
+		case <-revoked:
+			if !streamAuthorized(c) {
+				return
+			}
+		}
+	}
+}
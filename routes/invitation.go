@@ -0,0 +1,159 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"stock-dashboard/config"
+	"stock-dashboard/mailer"
+	"stock-dashboard/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type acceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+func invitationLink(token string) string {
+	base := config.GetEnv("INVITATION_URL", "http://localhost:3000/accept-invite")
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+func CreateInvitation(c *gin.Context) {
+	if models.RegistrationMode() == models.RegistrationClosed {
+		response := models.NewErrorResponse("Registration is closed")
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	var invitation models.Invitation
+	err := c.ShouldBindJSON(&invitation)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request data: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	invitedBy := c.GetString("userID")
+	invitation.InvitedBy = &invitedBy
+
+	ttl := time.Duration(config.GetEnvInt("INVITATION_TTL_HOURS", 72)) * time.Hour
+	token, err := invitation.Save(ttl)
+	if errors.Is(err, models.ErrEmailTaken) {
+		response := models.NewErrorResponse("A user with this email already exists")
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to create invitation")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      invitation.Email,
+		Subject: "You've been invited to Stock Dashboard",
+		Body: fmt.Sprintf("You've been invited to join Stock Dashboard as %s.\n\n"+
+			"Use the link below before %s to set your password:\n%s",
+			invitation.Role, invitation.ExpiresAt.Format(time.RFC1123), invitationLink(token)),
+	}
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Printf("❌ Failed to send invitation %d: %v", invitation.ID, err)
+		}
+	}()
+
+	recordAudit(c, AuditCreate, "invitation", strconv.FormatInt(invitation.ID, 10), nil, invitation)
+
+	response := models.NewSuccessResponse(invitation, "Invitation sent successfully")
+	c.JSON(http.StatusCreated, response)
+}
+
+func GetInvitations(c *gin.Context) {
+	invitations, err := models.GetPendingInvitations()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch invitations")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := models.NewSuccessResponse(invitations, "Invitations fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid invitation ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	invitation := models.Invitation{ID: id}
+	err = invitation.Revoke()
+	if errors.Is(err, models.ErrInvitationNotFound) {
+		response := models.NewErrorResponse("Pending invitation not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to revoke invitation")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditRevoke, "invitation", c.Param("id"), gin.H{"email": invitation.Email, "role": invitation.Role}, nil)
+
+	response := models.NewSuccessResponse(nil, "Invitation revoked successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func AcceptInvitation(c *gin.Context) {
+	if models.RegistrationMode() == models.RegistrationClosed {
+		response := models.NewErrorResponse("Registration is closed")
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	var request acceptInvitationRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Token and a password of at least 8 characters are required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	user, err := models.AcceptInvitation(request.Token, request.Password)
+	if errors.Is(err, models.ErrInvalidInvitation) {
+		response := models.NewErrorResponse("Invitation is invalid or has expired")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if errors.Is(err, models.ErrEmailTaken) {
+		response := models.NewErrorResponse("A user with this email already exists")
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to accept invitation")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"role":    user.Role,
+	}
+	c.Set("userID", user.ID)
+	recordAudit(c, AuditCreate, "user", user.ID, nil, data)
+
+	response := models.NewSuccessResponse(data, "Invitation accepted, you can now log in")
+	c.JSON(http.StatusCreated, response)
+}
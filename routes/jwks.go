@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"stock-dashboard/models"
+	"stock-dashboard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public signing keys in the standard JWK Set format
+// so other services can verify our tokens without sharing a secret.
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(utils.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, gin.H{"keys": utils.PublicJWKS()})
+}
+
+func RotateSigningKey(c *gin.Context) {
+	opts := models.SigningKeyOptions()
+	if opts.Algorithm == utils.AlgHS256 {
+		response := models.NewErrorResponse("Tokens are signed with the shared secret, there is no key to rotate")
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+
+	kid, err := models.EnsureSigningKey(opts, true)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to rotate signing key")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	err = models.LoadSigningKeys()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to reload signing keys")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditCreate, "signing_key", kid, nil, gin.H{"kid": kid, "algorithm": opts.Algorithm})
+
+	response := models.NewSuccessResponse(gin.H{"kid": kid}, "Signing key rotated successfully")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,194 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"stock-dashboard/models"
+	"stock-dashboard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateMeRequest struct {
+	Name            *string `json:"name" binding:"omitempty,max=255"`
+	Email           string  `json:"email" binding:"omitempty,email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+func currentUser(c *gin.Context) (*models.User, bool) {
+	user := models.User{ID: c.GetString("userID")}
+	err := user.Get()
+	if errors.Is(err, models.ErrUserNotFound) {
+		response := models.NewErrorResponse("User not found")
+		c.JSON(http.StatusNotFound, response)
+		return nil, false
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, response)
+		return nil, false
+	}
+	return &user, true
+}
+
+func reissueToken(c *gin.Context, mfa bool) (string, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return "", false
+	}
+	version, err := models.GetTokenVersion(user.ID)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to issue new token")
+		c.JSON(http.StatusInternalServerError, response)
+		return "", false
+	}
+	// Keep the session making the change signed in after its old tokens
+	// were revoked.
+	sessionID := c.GetString("sessionID")
+	if sessionID != "" {
+		err = models.RefreshSession(sessionID, version)
+		if err != nil {
+			response := models.NewErrorResponse("Failed to issue new token")
+			c.JSON(http.StatusInternalServerError, response)
+			return "", false
+		}
+	}
+	token, err := utils.GenerateToken(user.Email, user.ID, user.Role, version, mfa, sessionID)
+	if err != nil {
+		response := models.NewErrorResponse("Could not generate token")
+		c.JSON(http.StatusInternalServerError, response)
+		return "", false
+	}
+	return token, true
+}
+
+func meData(user *models.User) gin.H {
+	return gin.H{
+		"user":        user,
+		"permissions": models.PermissionsForRole(user.Role),
+	}
+}
+
+func checkCurrentPassword(c *gin.Context, password string) bool {
+	ok, err := models.CheckUserPassword(c.GetString("userID"), password)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to verify password")
+		c.JSON(http.StatusInternalServerError, response)
+		return false
+	}
+	if !ok {
+		response := models.NewErrorResponse("Current password is incorrect")
+		c.JSON(http.StatusForbidden, response)
+		return false
+	}
+	return true
+}
+
+func GetMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	response := models.NewSuccessResponse(meData(user), "Profile fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func UpdateMe(c *gin.Context) {
+	var request updateMeRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request data: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	before, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// Changing the login email is treated like a credential change.
+	if request.Email != "" && request.Email != before.Email {
+		if request.CurrentPassword == "" {
+			response := models.NewErrorResponse("Current password is required to change email")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		if !checkCurrentPassword(c, request.CurrentPassword) {
+			return
+		}
+	}
+
+	update := models.UserUpdate{ID: before.ID, Name: request.Name, Email: request.Email}
+	err = update.Update()
+	if errors.Is(err, models.ErrEmailTaken) {
+		response := models.NewErrorResponse("Email is already in use")
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to update profile")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	after, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	data := meData(after)
+	// An email change revokes every token like a password change does, so
+	// hand the caller a fresh one carrying the new email.
+	if update.Revoked() {
+		token, ok := reissueToken(c, c.GetBool("mfa"))
+		if !ok {
+			return
+		}
+		data["accessToken"] = token
+	}
+	recordAudit(c, AuditUpdate, "user", after.ID, userSnapshot(*before), userSnapshot(*after))
+
+	response := models.NewSuccessResponse(data, "Profile updated successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func ChangeMyPassword(c *gin.Context) {
+	var request changePasswordRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Current password and a new password of at least 8 characters are required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if !checkCurrentPassword(c, request.CurrentPassword) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	update := models.UserUpdate{ID: userID, Password: request.NewPassword}
+	err = update.Update()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to change password")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	// The change revoked every token, including this one, so hand the
+	// caller a fresh token to stay signed in.
+	token, ok := reissueToken(c, c.GetBool("mfa"))
+	if !ok {
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "user", userID, nil, gin.H{"password_changed": true})
+
+	response := models.NewSuccessResponse(gin.H{"accessToken": token}, "Password changed successfully")
+	c.JSON(http.StatusOK, response)
+}
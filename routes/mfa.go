@@ -0,0 +1,262 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"stock-dashboard/models"
+	"stock-dashboard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mfaLoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type disableMFARequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Code            string `json:"code"`
+	RecoveryCode    string `json:"recovery_code"`
+}
+
+func mfaError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidMFACode):
+		response := models.NewErrorResponse("Invalid two-factor code")
+		c.JSON(http.StatusUnauthorized, response)
+	case errors.Is(err, models.ErrMFAAlreadyEnabled), errors.Is(err, models.ErrMFANotEnabled),
+		errors.Is(err, models.ErrMFANotStarted):
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, models.ErrUserNotFound):
+		response := models.NewErrorResponse("User not found")
+		c.JSON(http.StatusNotFound, response)
+	default:
+		response := models.NewErrorResponse(fallback)
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+func LoginMFA(c *gin.Context) {
+	var request mfaLoginRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil || (request.Code == "") == (request.RecoveryCode == "") {
+		response := models.NewErrorResponse("mfa_token and either code or recovery_code are required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	claims, err := utils.VerifyToken(request.MFAToken)
+	if err != nil || claims.Purpose != utils.PurposeMFA {
+		response := models.NewErrorResponse("Two-factor challenge is invalid or has expired")
+		c.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	ipKey := c.ClientIP()
+	userKey := "mfa:" + claims.UserID
+	if wait := max(ipLoginFailures.RetryAfter(ipKey), emailLoginFailures.RetryAfter(userKey)); wait > 0 {
+		tooManyLoginAttempts(c, wait)
+		return
+	}
+
+	err = models.VerifyMFA(claims.UserID, request.Code, request.RecoveryCode)
+	if errors.Is(err, models.ErrInvalidMFACode) {
+		ipLoginFailures.Fail(ipKey)
+		emailLoginFailures.Fail(userKey)
+	}
+	if err != nil {
+		mfaError(c, err, "Failed to verify two-factor code")
+		return
+	}
+	emailLoginFailures.Reset(userKey)
+
+	user := models.User{ID: claims.UserID}
+	err = user.Get()
+	if err != nil {
+		mfaError(c, err, "Failed to fetch user")
+		return
+	}
+	version, err := models.GetTokenVersion(user.ID)
+	if err != nil || version != claims.TokenVersion {
+		response := models.NewErrorResponse("Two-factor challenge is invalid or has expired")
+		c.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	token, err := issueSessionToken(c, &user, version, true, models.SessionMFA)
+	if err != nil {
+		response := models.NewErrorResponse("Could not generate token")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"accessToken": token,
+		"id":          user.ID,
+		"email":       user.Email,
+		"role":        user.Role,
+	}
+	response := models.NewSuccessResponse(data, "Login successful")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetMFAStatus(c *gin.Context) {
+	userID := c.GetString("userID")
+	enabled, err := models.IsMFAEnabled(userID)
+	if err != nil {
+		mfaError(c, err, "Failed to fetch two-factor status")
+		return
+	}
+
+	data := gin.H{"enabled": enabled}
+	if enabled {
+		remaining, err := models.CountRecoveryCodes(userID)
+		if err != nil {
+			mfaError(c, err, "Failed to fetch two-factor status")
+			return
+		}
+		data["recoveryCodesRemaining"] = remaining
+	}
+
+	response := models.NewSuccessResponse(data, "Two-factor status fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func SetupMFA(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	enrollment, err := models.BeginTOTPEnrollment(user.ID, user.Email)
+	if err != nil {
+		mfaError(c, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	// Never audit the secret itself.
+	recordAudit(c, AuditUpdate, "user", user.ID, nil, gin.H{"mfa_enrollment_started": true})
+
+	response := models.NewSuccessResponse(enrollment, "Scan the code with your authenticator app, then confirm it")
+	c.JSON(http.StatusOK, response)
+}
+
+func EnableMFA(c *gin.Context) {
+	var request mfaCodeRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Code is required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	userID := c.GetString("userID")
+	codes, err := models.EnableTOTP(userID, request.Code)
+	if err != nil {
+		mfaError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	// The user just proved the second factor, so upgrade the session.
+	token, ok := reissueToken(c, true)
+	if !ok {
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "user", userID, gin.H{"mfa_enabled": false}, gin.H{"mfa_enabled": true})
+
+	data := gin.H{
+		"recoveryCodes": codes,
+		"accessToken":   token,
+	}
+	response := models.NewSuccessResponse(data, "Two-factor authentication enabled, store the recovery codes safely")
+	c.JSON(http.StatusOK, response)
+}
+
+func DisableMFA(c *gin.Context) {
+	var request disableMFARequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil || (request.Code == "") == (request.RecoveryCode == "") {
+		response := models.NewErrorResponse("current_password and either code or recovery_code are required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if !checkCurrentPassword(c, request.CurrentPassword) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	err = models.VerifyMFA(userID, request.Code, request.RecoveryCode)
+	if err != nil {
+		mfaError(c, err, "Failed to verify two-factor code")
+		return
+	}
+
+	err = models.DisableTOTP(userID)
+	if err != nil {
+		mfaError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	token, ok := reissueToken(c, false)
+	if !ok {
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "user", userID, gin.H{"mfa_enabled": true}, gin.H{"mfa_enabled": false})
+
+	response := models.NewSuccessResponse(gin.H{"accessToken": token}, "Two-factor authentication disabled")
+	c.JSON(http.StatusOK, response)
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var request mfaCodeRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Code is required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	userID := c.GetString("userID")
+	err = models.VerifyMFA(userID, request.Code, "")
+	if err != nil {
+		mfaError(c, err, "Failed to verify two-factor code")
+		return
+	}
+
+	codes, err := models.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		mfaError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "user", userID, nil, gin.H{"recovery_codes_regenerated": true})
+
+	response := models.NewSuccessResponse(gin.H{"recoveryCodes": codes}, "Recovery codes regenerated")
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetUserMFA lets an admin clear two-factor for a user who lost their
+// device. The user's existing sessions are revoked.
+func ResetUserMFA(c *gin.Context) {
+	userID := c.Param("id")
+	err := models.DisableTOTP(userID)
+	if err != nil {
+		mfaError(c, err, "Failed to reset two-factor authentication")
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "user", userID, gin.H{"mfa_enabled": true}, gin.H{"mfa_enabled": false})
+
+	response := models.NewSuccessResponse(nil, "Two-factor authentication reset")
+	c.JSON(http.StatusOK, response)
+}
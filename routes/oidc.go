@@ -0,0 +1,305 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"stock-dashboard/config"
+	"stock-dashboard/models"
+	"stock-dashboard/oidc"
+	"stock-dashboard/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowTTL    = 10 * time.Minute
+)
+
+// oidcFlow is kept in an encrypted cookie between the redirect to the
+// provider and the callback, so any instance can finish the login.
+type oidcFlow struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	Expires  time.Time `json:"expires"`
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+)
+
+func oidcEnabled() bool {
+	return config.GetEnv("OIDC_ISSUER", "") != ""
+}
+
+func passwordLoginEnabled() bool {
+	return config.GetEnvBool("PASSWORD_LOGIN_ENABLED", true)
+}
+
+// getOIDCProvider discovers the provider on first use and retries on the
+// next request if discovery failed.
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	var scopes []string
+	if raw := config.GetEnv("OIDC_SCOPES", ""); raw != "" {
+		scopes = strings.Fields(strings.ReplaceAll(raw, ",", " "))
+	}
+
+	provider, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       config.GetEnv("OIDC_ISSUER", ""),
+		ClientID:     config.GetEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret: config.GetEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  config.GetEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		Scopes:       scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider = provider
+	return provider, nil
+}
+
+// oidcRole maps the configured claim to a role. Mapping is opt-in: unless
+// both OIDC_ROLE_CLAIM and OIDC_ADMIN_VALUES are set it returns "" and the
+// stored role is kept, so signing in can never demote an admin by accident.
+func oidcRole(claims jwt.MapClaims) string {
+	claim := config.GetEnv("OIDC_ROLE_CLAIM", "")
+	if claim == "" {
+		return ""
+	}
+
+	var admins []string
+	for _, admin := range strings.Split(config.GetEnv("OIDC_ADMIN_VALUES", ""), ",") {
+		if admin = strings.TrimSpace(admin); admin != "" {
+			admins = append(admins, admin)
+		}
+	}
+	if len(admins) == 0 {
+		return ""
+	}
+
+	var values []string
+	switch v := claims[claim].(type) {
+	case string:
+		values = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	for _, admin := range admins {
+		for _, value := range values {
+			if value == admin {
+				return "admin"
+			}
+		}
+	}
+	return config.GetEnv("OIDC_DEFAULT_ROLE", "staff")
+}
+
+// oidcProvisioningPolicy follows REGISTRATION_MODE so single sign-on cannot
+// be used to get round it: with open registration anyone may sign up, with
+// invitations only invitees and verified emails at OIDC_ALLOWED_DOMAINS may,
+// and closed registration provisions nobody. OIDC_AUTO_PROVISION=false
+// turns provisioning off altogether.
+func oidcProvisioningPolicy() models.ProvisioningPolicy {
+	mode := models.RegistrationMode()
+	if mode == models.RegistrationClosed || !config.GetEnvBool("OIDC_AUTO_PROVISION", true) {
+		return models.ProvisioningPolicy{}
+	}
+
+	policy := models.ProvisioningPolicy{Open: mode == models.RegistrationOpen, Invitations: true}
+	for _, domain := range strings.Split(config.GetEnv("OIDC_ALLOWED_DOMAINS", ""), ",") {
+		if domain = strings.TrimPrefix(strings.TrimSpace(domain), "@"); domain != "" {
+			policy.AllowedDomains = append(policy.AllowedDomains, domain)
+		}
+	}
+	return policy
+}
+
+// oidcMFA reports whether the provider says a second factor was used.
+func oidcMFA(claims jwt.MapClaims) bool {
+	if config.GetEnvBool("OIDC_TRUST_MFA", false) {
+		return true
+	}
+	amr, _ := claims["amr"].([]any)
+	for _, method := range amr {
+		switch method {
+		case "mfa", "otp", "hwk", "swk", "fido":
+			return true
+		}
+	}
+	return false
+}
+
+func oidcFrontendRedirect(c *gin.Context, fragment url.Values) {
+	target := config.GetEnv("OIDC_FRONTEND_REDIRECT", "http://localhost:3000/auth/callback")
+	// The fragment keeps the token out of server and proxy logs.
+	c.Redirect(http.StatusFound, target+"#"+fragment.Encode())
+}
+
+func oidcFail(c *gin.Context, message string) {
+	oidcFrontendRedirect(c, url.Values{"error": {message}})
+}
+
+func GetAuthProviders(c *gin.Context) {
+	data := gin.H{
+		"password": passwordLoginEnabled(),
+		"oidc":     oidcEnabled(),
+	}
+	response := models.NewSuccessResponse(data, "Authentication providers fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func OIDCLogin(c *gin.Context) {
+	if !oidcEnabled() {
+		response := models.NewErrorResponse("Single sign-on is not configured")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		log.Printf("❌ OIDC discovery failed: %v", err)
+		response := models.NewErrorResponse("Identity provider is unavailable")
+		c.JSON(http.StatusBadGateway, response)
+		return
+	}
+
+	flow := oidcFlow{Expires: time.Now().Add(oidcFlowTTL)}
+	for _, field := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		*field, err = oidc.RandomString()
+		if err != nil {
+			response := models.NewErrorResponse("Could not start sign-on")
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+	}
+
+	payload, _ := json.Marshal(flow)
+	sealed, err := utils.EncryptSecret(string(payload))
+	if err != nil {
+		response := models.NewErrorResponse("Could not start sign-on")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	secure := strings.HasPrefix(config.GetEnv("OIDC_REDIRECT_URL", ""), "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, sealed, int(oidcFlowTTL.Seconds()), "/api/auth/oidc", "", secure, true)
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(flow.State, flow.Nonce, flow.Verifier))
+}
+
+func OIDCCallback(c *gin.Context) {
+	if !oidcEnabled() {
+		response := models.NewErrorResponse("Single sign-on is not configured")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	sealed, err := c.Cookie(oidcFlowCookie)
+	c.SetCookie(oidcFlowCookie, "", -1, "/api/auth/oidc", "", false, true)
+	if err != nil {
+		oidcFail(c, "Sign-on session expired, please try again")
+		return
+	}
+
+	var flow oidcFlow
+	payload, err := utils.DecryptSecret(sealed)
+	if err != nil || json.Unmarshal([]byte(payload), &flow) != nil || time.Now().After(flow.Expires) {
+		oidcFail(c, "Sign-on session expired, please try again")
+		return
+	}
+	if c.Query("state") != flow.State {
+		oidcFail(c, "Sign-on state mismatch")
+		return
+	}
+	if providerError := c.Query("error"); providerError != "" {
+		oidcFail(c, "Identity provider returned "+providerError)
+		return
+	}
+
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		log.Printf("❌ OIDC discovery failed: %v", err)
+		oidcFail(c, "Identity provider is unavailable")
+		return
+	}
+
+	idToken, err := provider.Exchange(c.Request.Context(), c.Query("code"), flow.Verifier)
+	if err != nil {
+		log.Printf("❌ OIDC code exchange failed: %v", err)
+		oidcFail(c, "Could not complete sign-on")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(c.Request.Context(), idToken, flow.Nonce)
+	if err != nil {
+		log.Printf("❌ OIDC ID token rejected: %v", err)
+		oidcFail(c, "Could not complete sign-on")
+		return
+	}
+
+	identity := models.OIDCIdentity{
+		Subject: config.GetEnv("OIDC_ISSUER", "") + "|" + claimString(claims, "sub"),
+		Email:   claimString(claims, "email"),
+		Name:    claimString(claims, "name"),
+		Role:    oidcRole(claims),
+	}
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+
+	user, err := models.FindOrProvisionOIDCUser(identity, oidcProvisioningPolicy())
+	switch {
+	case errors.Is(err, models.ErrProvisioningDisabled):
+		oidcFail(c, "No account exists for this identity")
+		return
+	case errors.Is(err, models.ErrEmailTaken):
+		oidcFail(c, "An account with this email already exists, ask an admin to link it")
+		return
+	case errors.Is(err, models.ErrUserDisabled):
+		oidcFail(c, "Account is disabled")
+		return
+	case err != nil:
+		log.Printf("❌ OIDC user lookup failed: %v", err)
+		oidcFail(c, "Could not complete sign-on")
+		return
+	}
+
+	token, err := issueSessionToken(c, user, user.TokenVersion, oidcMFA(claims), models.SessionOIDC)
+	if err != nil {
+		oidcFail(c, "Could not generate token")
+		return
+	}
+
+	oidcFrontendRedirect(c, url.Values{
+		"accessToken": {token},
+		"id":          {user.ID},
+		"email":       {user.Email},
+		"role":        {user.Role},
+	})
+}
+
+func claimString(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
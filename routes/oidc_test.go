@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"stock-dashboard/models"
+	"stock-dashboard/utils"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestOIDCRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		claim  string
+		admins string
+		claims jwt.MapClaims
+		want   string
+	}{
+		{"mapping off", "", "", jwt.MapClaims{"groups": []any{"admins"}}, ""},
+		{"claim without admin values", "groups", "", jwt.MapClaims{"groups": []any{"admins"}}, ""},
+		{"admin values without claim", "", "admins", jwt.MapClaims{"groups": []any{"admins"}}, ""},
+		{"admin group", "groups", "ops, admins", jwt.MapClaims{"groups": []any{"staff", "admins"}}, "admin"},
+		{"space separated claim", "roles", "admins", jwt.MapClaims{"roles": "staff admins"}, "admin"},
+		{"other group", "groups", "admins", jwt.MapClaims{"groups": []any{"staff"}}, "staff"},
+		{"claim missing", "groups", "admins", jwt.MapClaims{}, "staff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OIDC_ROLE_CLAIM", tt.claim)
+			t.Setenv("OIDC_ADMIN_VALUES", tt.admins)
+			t.Setenv("OIDC_DEFAULT_ROLE", "")
+			if got := oidcRole(tt.claims); got != tt.want {
+				t.Errorf("oidcRole() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCProvisioningPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		autoProvision string
+		domains       string
+		want          models.ProvisioningPolicy
+	}{
+		{"closed registration", "closed", "", "example.com", models.ProvisioningPolicy{}},
+		{"provisioning off", "open", "false", "", models.ProvisioningPolicy{}},
+		{"invite only", "invite", "", "", models.ProvisioningPolicy{Invitations: true}},
+		{"invite only with domains", "", "", " example.com, @corp.example ,", models.ProvisioningPolicy{
+			Invitations: true, AllowedDomains: []string{"example.com", "corp.example"}}},
+		{"open registration", "open", "", "", models.ProvisioningPolicy{Open: true, Invitations: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REGISTRATION_MODE", tt.mode)
+			t.Setenv("OIDC_AUTO_PROVISION", tt.autoProvision)
+			t.Setenv("OIDC_ALLOWED_DOMAINS", tt.domains)
+			if got := oidcProvisioningPolicy(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("oidcProvisioningPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// setupOIDC points the routes at a provider that only serves discovery;
+// the flow tests below never get as far as the code exchange.
+func setupOIDC(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var issuer string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"jwks_uri":               issuer + "/jwks",
+		})
+	}))
+	issuer = provider.URL
+	t.Cleanup(provider.Close)
+
+	t.Setenv("OIDC_ISSUER", issuer)
+	t.Setenv("OIDC_CLIENT_ID", "dashboard")
+	t.Setenv("OIDC_FRONTEND_REDIRECT", "http://app.test/callback")
+	t.Setenv("MFA_ENCRYPTION_KEY", "test-encryption-key")
+	oidcProvider = nil
+	t.Cleanup(func() { oidcProvider = nil })
+
+	router := gin.New()
+	router.GET("/api/auth/oidc/login", OIDCLogin)
+	router.GET("/api/auth/oidc/callback", OIDCCallback)
+	return router
+}
+
+func TestOIDCLoginStartsFlow(t *testing.T) {
+	router := setupOIDC(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcFlowCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("flow cookie = %+v, want an HttpOnly cookie", cookie)
+	}
+	sealed, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := utils.DecryptSecret(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flow oidcFlow
+	if err := json.Unmarshal([]byte(payload), &flow); err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	sum := sha256.Sum256([]byte(flow.Verifier))
+	if query.Get("state") != flow.State || query.Get("nonce") != flow.Nonce {
+		t.Errorf("state/nonce in redirect do not match the flow cookie")
+	}
+	if query.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Errorf("code_challenge is not the S256 hash of the flow's verifier")
+	}
+}
+
+func TestOIDCCallbackChecksFlow(t *testing.T) {
+	router := setupOIDC(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	var sealed string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcFlowCookie {
+			sealed = c.Value
+		}
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	state := location.Query().Get("state")
+
+	tests := []struct {
+		name   string
+		cookie string
+		state  string
+		want   string
+	}{
+		{"no flow cookie", "", state, "Sign-on session expired, please try again"},
+		{"tampered cookie", sealed[:len(sealed)-4] + "AAAA", state, "Sign-on session expired, please try again"},
+		{"state mismatch", sealed, "forged", "Sign-on state mismatch"},
+		{"missing state", sealed, "", "Sign-on state mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet,
+				"/api/auth/oidc/callback?code=abc&state="+url.QueryEscape(tt.state), nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcFlowCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			redirect, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fragment, _ := url.ParseQuery(redirect.Fragment)
+			if got := fragment.Get("error"); got != tt.want {
+				t.Errorf("error = %q, want %q", got, tt.want)
+			}
+			if fragment.Has("token") {
+				t.Error("callback issued a token")
+			}
+		})
+	}
+}
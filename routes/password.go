@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"stock-dashboard/config"
+	"stock-dashboard/mailer"
+	"stock-dashboard/models"
+	"stock-dashboard/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const AuditPasswordReset = "password_reset"
+
+// Every reset request counts against the client IP and the address it targets,
+// so the endpoint cannot be used to flood someone's inbox.
+var (
+	ipResetRequests    = utils.NewFailureTracker(10, 15*time.Minute, time.Hour)
+	emailResetRequests = utils.NewFailureTracker(3, time.Hour, time.Hour)
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+func passwordResetLink(token string) string {
+	base := config.GetEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+func ForgotPassword(c *gin.Context) {
+	if !passwordLoginEnabled() {
+		response := models.NewErrorResponse("Password login is disabled, use single sign-on")
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	var request forgotPasswordRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("A valid email is required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	ipKey := c.ClientIP()
+	emailKey := strings.ToLower(request.Email)
+	if wait := max(ipResetRequests.RetryAfter(ipKey), emailResetRequests.RetryAfter(emailKey)); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		response := models.NewErrorResponse("Too many password reset requests, please try again later")
+		c.JSON(http.StatusTooManyRequests, response)
+		return
+	}
+	ipResetRequests.Fail(ipKey)
+	emailResetRequests.Fail(emailKey)
+
+	// The response is the same whether or not the account exists, so the
+	// endpoint cannot be used to discover registered emails.
+	response := models.NewSuccessResponse(nil, "If the email is registered, a reset link has been sent")
+
+	ttl := time.Duration(config.GetEnvInt("PASSWORD_RESET_TTL_MINUTES", 30)) * time.Minute
+	token, user, err := models.CreatePasswordResetToken(request.Email, ttl)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Could not start password reset")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for this account.\n\n"+
+			"Use the link below within %d minutes to choose a new one:\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.", int(ttl.Minutes()), passwordResetLink(token)),
+	}
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Printf("❌ Failed to send password reset email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ResetPassword(c *gin.Context) {
+	if !passwordLoginEnabled() {
+		response := models.NewErrorResponse("Password login is disabled, use single sign-on")
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	var request resetPasswordRequest
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Token and a password of at least 8 characters are required")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	userID, err := models.ResetPassword(request.Token, request.Password)
+	if errors.Is(err, models.ErrInvalidResetToken) {
+		response := models.NewErrorResponse("Reset link is invalid or has expired")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Could not reset password")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	c.Set("userID", userID)
+	recordAudit(c, AuditPasswordReset, "user", userID, nil, nil)
+
+	response := models.NewSuccessResponse(nil, "Password has been reset, please log in again")
+	c.JSON(http.StatusOK, response)
+}
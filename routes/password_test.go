@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		setup func()
+		email string
+	}{
+		{"same email", func() {
+			for range 4 {
+				emailResetRequests.Fail("victim@example.com")
+			}
+		}, "Victim@Example.com"},
+		{"same client", func() {
+			for range 11 {
+				ipResetRequests.Fail("192.0.2.1")
+			}
+		}, "someone@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				emailResetRequests.Reset("victim@example.com")
+				ipResetRequests.Reset("192.0.2.1")
+			})
+			tt.setup()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(`{"email":"`+tt.email+`"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+
+			ForgotPassword(c)
+
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After header missing")
+			}
+		})
+	}
+}
@@ -1,16 +1,19 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
+	"stock-dashboard/config"
 	"stock-dashboard/models"
+	"stock-dashboard/utils"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetProducts(c *gin.Context) {
-	var filter models.ProductFilter
-
+func parseProductFilter(c *gin.Context, filter models.ProductFilter) (models.ProductFilter, error) {
 	if name := c.Query("name"); name != "" {
 		filter.Name = name
 	}
@@ -37,16 +40,32 @@ func GetProducts(c *gin.Context) {
 			filter.MaxStock = stock
 		}
 	}
+	if abcClass := strings.ToUpper(c.Query("abc_class")); abcClass == "A" || abcClass == "B" || abcClass == "C" {
+		filter.ABCClass = abcClass
+	}
+	if xyzClass := strings.ToUpper(c.Query("xyz_class")); xyzClass == "X" || xyzClass == "Y" || xyzClass == "Z" {
+		filter.XYZClass = xyzClass
+	}
 	if sortOrder := c.Query("sort_order"); sortOrder != "" {
 		if sortOrder == "asc" || sortOrder == "desc" {
 			filter.SortOrder = sortOrder
 		}
 	}
+	if sort := c.Query("sort"); sort != "" {
+		parse := models.ParseProductSort
+		if filter.Trashed {
+			parse = models.ParseTrashedProductSort
+		}
+		if _, err := parse(sort); err != nil {
+			return filter, err
+		}
+		filter.Sort = sort
+	}
 	if limit := c.Query("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil && l > 0 {
 			filter.Limit = l
 		}
-	} else {
+	} else if filter.Limit <= 0 {
 		filter.Limit = 10
 	}
 	if page := c.Query("page"); page != "" {
@@ -57,6 +76,47 @@ func GetProducts(c *gin.Context) {
 		filter.Offset = 1
 	}
 
+	return filter, nil
+}
+
+func GetProducts(c *gin.Context) {
+	var base models.ProductFilter
+	var view *models.SavedView
+
+	if viewParam := c.Query("view"); viewParam != "" {
+		id, err := strconv.ParseInt(viewParam, 10, 64)
+		if err != nil {
+			response := models.NewErrorResponse("Invalid view ID")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		view = &models.SavedView{ID: id}
+		err = view.Get(c.GetString("userID"))
+		if err != nil {
+			response := models.NewErrorResponse("Saved view not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+
+		base = view.Filter
+		if view.Sort != "" {
+			base.Sort = view.Sort
+		}
+	}
+
+	filter, err := parseProductFilter(c, base)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid sort parameter: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		getProductsWithCursor(c, filter, cursor, view)
+		return
+	}
+
 	result, err := models.GetProductsWithPagination(filter)
 	if err != nil {
 		response := models.NewErrorResponse("Failed to fetch products")
@@ -72,6 +132,41 @@ func GetProducts(c *gin.Context) {
 		"total":      result.Total,
 		"totalPages": result.TotalPages,
 	}
+	if view != nil {
+		data["view"] = view
+	}
+	response := models.NewSuccessResponse(data, "Products fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func getProductsWithCursor(c *gin.Context, filter models.ProductFilter, cursor string, view *models.SavedView) {
+	includeTotal, _ := strconv.ParseBool(c.Query("include_total"))
+
+	result, err := models.GetProductsWithCursor(filter, cursor, includeTotal)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			response := models.NewErrorResponse("Invalid or expired cursor")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to fetch products")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"products":   result.Products,
+		"count":      len(result.Products),
+		"limit":      result.Limit,
+		"nextCursor": result.NextCursor,
+		"hasMore":    result.HasMore,
+	}
+	if result.Total != nil {
+		data["total"] = *result.Total
+	}
+	if view != nil {
+		data["view"] = view
+	}
 	response := models.NewSuccessResponse(data, "Products fetched successfully")
 	c.JSON(http.StatusOK, response)
 }
@@ -94,6 +189,12 @@ func GetProduct(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", productETag(product.Version))
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, product.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	data := gin.H{
 		"product": product,
 	}
@@ -118,6 +219,11 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	productID := strconv.FormatInt(product.ID, 10)
+	recordAudit(c, AuditCreate, "product", productID, nil, product)
+
+	c.Header("ETag", productETag(product.Version))
+
 	data := gin.H{
 		"product": product,
 	}
@@ -134,6 +240,11 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
 	var product models.ProductUpdate
 	err = c.ShouldBindJSON(&product)
 	if err != nil {
@@ -143,13 +254,27 @@ func UpdateProduct(c *gin.Context) {
 	}
 
 	product.ID = id
+	product.Version = version
 	err = product.Update()
 	if err != nil {
+		if errors.Is(err, models.ErrProductNotFound) {
+			response := models.NewErrorResponse("Product not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		if errors.Is(err, models.ErrVersionMismatch) {
+			response := models.NewErrorResponse("Product has been modified by someone else")
+			c.JSON(http.StatusPreconditionFailed, response)
+			return
+		}
 		response := models.NewErrorResponse("Failed to update product")
 		c.JSON(http.StatusInternalServerError, response)
 		return
 	}
 
+	recordAudit(c, AuditUpdate, "product", idParam, product.Before(), product.After())
+
+	c.Header("ETag", productETag(product.Version))
 	data := gin.H{
 		"product": product,
 	}
@@ -166,15 +291,36 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
+	version, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	deletedBy := c.GetString("userID")
+
 	var product models.Product
 	product.ID = id
-	err = product.Delete()
+	product.DeletedBy = &deletedBy
+	product.Version = version
+	before, err := product.Delete()
 	if err != nil {
+		if errors.Is(err, models.ErrProductNotFound) {
+			response := models.NewErrorResponse("Product not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		if errors.Is(err, models.ErrVersionMismatch) {
+			response := models.NewErrorResponse("Product has been modified by someone else")
+			c.JSON(http.StatusPreconditionFailed, response)
+			return
+		}
 		response := models.NewErrorResponse("Failed to delete product")
 		c.JSON(http.StatusInternalServerError, response)
 		return
 	}
 
+	recordAudit(c, AuditDelete, "product", idParam, before, nil)
+
 	data := gin.H{
 		"product_id": id,
 	}
@@ -183,19 +329,21 @@ func DeleteProduct(c *gin.Context) {
 }
 
 func SearchProducts(c *gin.Context) {
-	searchTerm := c.Query("q")
+	searchTerm := strings.TrimSpace(c.Query("q"))
 	if searchTerm == "" {
 		response := models.NewErrorResponse("Search term is required")
 		c.JSON(http.StatusBadRequest, response)
 		return
 	}
 
-	sortOrder := c.Query("sort_order")
-	if sortOrder != "asc" && sortOrder != "desc" {
-		sortOrder = "desc"
+	filter, err := parseProductFilter(c, models.ProductFilter{})
+	if err != nil {
+		response := models.NewErrorResponse("Invalid sort parameter: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
 	}
 
-	products, err := models.SearchProducts(searchTerm, sortOrder)
+	result, err := models.SearchProducts(searchTerm, filter)
 	if err != nil {
 		response := models.NewErrorResponse("Failed to search products")
 		c.JSON(http.StatusInternalServerError, response)
@@ -203,10 +351,154 @@ func SearchProducts(c *gin.Context) {
 	}
 
 	data := gin.H{
-		"products": products,
-		"count":    len(products),
-		"term":     searchTerm,
+		"products":   result.Hits,
+		"count":      len(result.Hits),
+		"term":       searchTerm,
+		"page":       result.Page,
+		"limit":      result.Limit,
+		"total":      result.Total,
+		"totalPages": result.TotalPages,
 	}
 	response := models.NewSuccessResponse(data, "Search completed successfully")
 	c.JSON(http.StatusOK, response)
 }
+
+func GetTrashedProducts(c *gin.Context) {
+	filter, err := parseProductFilter(c, models.ProductFilter{Sort: "-deleted_at", Trashed: true})
+	if err != nil {
+		response := models.NewErrorResponse("Invalid sort parameter: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	result, err := models.GetProductsWithPagination(filter)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch deleted products")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"products":   result.Products,
+		"count":      len(result.Products),
+		"page":       result.Page,
+		"limit":      result.Limit,
+		"total":      result.Total,
+		"totalPages": result.TotalPages,
+	}
+	response := models.NewSuccessResponse(data, "Deleted products fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RestoreProduct(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid product ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	product := models.Product{ID: id}
+	err = product.Restore()
+	if err != nil {
+		if errors.Is(err, models.ErrProductNotFound) {
+			response := models.NewErrorResponse("Deleted product not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to restore product")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditRestore, "product", idParam, nil, product)
+
+	data := gin.H{
+		"product": product,
+	}
+	response := models.NewSuccessResponse(data, "Product restored successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func PurgeTrashedProducts(c *gin.Context) {
+	retentionDays := config.GetEnvInt("PRODUCT_TRASH_RETENTION_DAYS", 30)
+
+	olderThanDays := retentionDays
+	if days := c.Query("older_than_days"); days != "" {
+		d, err := strconv.Atoi(days)
+		if err != nil || d < retentionDays {
+			response := models.NewErrorResponse("older_than_days must be at least the retention period of " + strconv.Itoa(retentionDays) + " days")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		olderThanDays = d
+	}
+
+	purged, err := models.PurgeDeletedProducts(time.Now().AddDate(0, 0, -olderThanDays))
+	if err != nil {
+		response := models.NewErrorResponse("Failed to purge deleted products")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditPurge, "product", "*", nil, gin.H{"purged": purged, "olderThanDays": olderThanDays})
+
+	data := gin.H{
+		"purged":        purged,
+		"olderThanDays": olderThanDays,
+	}
+	response := models.NewSuccessResponse(data, "Deleted products purged successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func BulkProducts(c *gin.Context) {
+	var request models.BulkRequest
+
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request format")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	request.ActorID = c.GetString("userID")
+	result, err := models.ExecuteBulk(request)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidBulkRequest) {
+			response := models.NewErrorResponse(err.Error())
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to apply bulk operations")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if result.Committed {
+		for _, item := range result.Results {
+			if item.Status != models.BulkStatusOK {
+				continue
+			}
+			action := AuditUpdate
+			if item.Op == models.BulkOpDelete {
+				action = AuditDelete
+			}
+			recordAudit(c, action, "product", strconv.FormatInt(item.ID, 10), item.Before, item.After)
+		}
+	}
+
+	status := http.StatusOK
+	message := "Bulk operations applied successfully"
+	if !result.Committed {
+		status = http.StatusConflict
+		message = "Bulk operations rolled back"
+	} else if result.Failed > 0 {
+		status = http.StatusMultiStatus
+		message = "Bulk operations partially applied"
+	}
+
+	response := models.NewSuccessResponse(result, message)
+	response.Success = result.Committed && result.Failed == 0
+	c.JSON(status, response)
+}
@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"encoding/csv"
+	"math"
+	"net/http"
+	"stock-dashboard/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetReorderSuggestions(c *gin.Context) {
+	opts := models.ForecastOptions{
+		Method:       c.DefaultQuery("method", models.ForecastExponentialSmoothing),
+		LeadTimeDays: 7,
+		ReviewDays:   14,
+		SafetyDays:   3,
+	}
+
+	if windowDays := c.Query("window_days"); windowDays != "" {
+		if w, err := strconv.Atoi(windowDays); err == nil && w > 0 && w <= 365 {
+			opts.WindowDays = w
+		}
+	}
+	if alpha := c.Query("alpha"); alpha != "" {
+		if a, err := strconv.ParseFloat(alpha, 64); err == nil {
+			opts.Alpha = a
+		}
+	}
+	if seasonal := c.Query("seasonal"); seasonal != "" {
+		opts.Seasonal, _ = strconv.ParseBool(seasonal)
+	}
+	if leadTime := c.Query("lead_time_days"); leadTime != "" {
+		if l, err := strconv.Atoi(leadTime); err == nil && l >= 0 {
+			opts.LeadTimeDays = l
+		}
+	}
+	if reviewDays := c.Query("review_days"); reviewDays != "" {
+		if r, err := strconv.Atoi(reviewDays); err == nil && r >= 0 {
+			opts.ReviewDays = r
+		}
+	}
+	if safetyDays := c.Query("safety_days"); safetyDays != "" {
+		if s, err := strconv.Atoi(safetyDays); err == nil && s >= 0 {
+			opts.SafetyDays = s
+		}
+	}
+	if minOrder := c.Query("min_order_qty"); minOrder != "" {
+		if m, err := strconv.Atoi(minOrder); err == nil && m >= 0 {
+			opts.MinOrderQty = m
+		}
+	}
+	if packSize := c.Query("pack_size"); packSize != "" {
+		if p, err := strconv.Atoi(packSize); err == nil && p > 0 {
+			opts.PackSize = p
+		}
+	}
+	if includeAll := c.Query("include_all"); includeAll != "" {
+		opts.IncludeAll, _ = strconv.ParseBool(includeAll)
+	}
+
+	suggestions, opts, err := models.GetReorderSuggestions(opts)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to compute reorder suggestions")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+		"options":     opts,
+	}
+	response := models.NewSuccessResponse(data, "Reorder suggestions computed successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func parseClassificationOptions(c *gin.Context) models.ClassificationOptions {
+	var opts models.ClassificationOptions
+
+	if windowDays := c.Query("window_days"); windowDays != "" {
+		if w, err := strconv.Atoi(windowDays); err == nil && w > 0 && w <= 730 {
+			opts.WindowDays = w
+		}
+	}
+	if a := c.Query("a_threshold"); a != "" {
+		opts.AThreshold, _ = strconv.ParseFloat(a, 64)
+	}
+	if b := c.Query("b_threshold"); b != "" {
+		opts.BThreshold, _ = strconv.ParseFloat(b, 64)
+	}
+	if x := c.Query("x_threshold"); x != "" {
+		opts.XThreshold, _ = strconv.ParseFloat(x, 64)
+	}
+	if y := c.Query("y_threshold"); y != "" {
+		opts.YThreshold, _ = strconv.ParseFloat(y, 64)
+	}
+
+	return opts
+}
+
+func GetInventoryClassification(c *gin.Context) {
+	report, err := models.ClassifyInventory(parseClassificationOptions(c))
+	if err != nil {
+		response := models.NewErrorResponse("Failed to classify inventory")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := models.NewSuccessResponse(report, "Inventory classification computed successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RecalculateInventoryClassification(c *gin.Context) {
+	report, err := models.RecalculateClassifications(parseClassificationOptions(c))
+	if err != nil {
+		response := models.NewErrorResponse("Failed to recalculate inventory classification")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditRecalculate, "inventory_classification", "", nil,
+		gin.H{"options": report.Options, "matrix": report.Matrix, "products": len(report.Classifications)})
+
+	response := models.NewSuccessResponse(report, "Inventory classification saved successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetDeadStock(c *gin.Context) {
+	var opts models.DeadStockOptions
+
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			opts.Days = d
+		}
+	}
+	if includeSlow := c.Query("include_slow"); includeSlow != "" {
+		opts.IncludeSlow, _ = strconv.ParseBool(includeSlow)
+	}
+	if slowCover := c.Query("slow_cover_days"); slowCover != "" {
+		if s, err := strconv.Atoi(slowCover); err == nil && s > 0 {
+			opts.SlowCoverDays = s
+		}
+	}
+
+	sort, err := models.ParseProductSort(c.Query("sort"))
+	if err != nil {
+		response := models.NewErrorResponse("Invalid sort parameter: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	opts.Sort = sort
+
+	items, err := models.GetDeadStock(opts)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch dead stock report")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		writeDeadStockCSV(c, items)
+		return
+	}
+
+	var totalValue float64
+	for _, item := range items {
+		totalValue += item.Value
+	}
+
+	data := gin.H{
+		"items":      items,
+		"count":      len(items),
+		"totalValue": math.Round(totalValue*100) / 100,
+	}
+	response := models.NewSuccessResponse(data, "Dead stock report fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+// csvSafe stops spreadsheets from evaluating a user-supplied cell as a
+// formula.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func writeDeadStockCSV(c *gin.Context, items []models.DeadStockItem) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="dead-stock.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"product_id", "name", "category", "price", "stock", "value",
+		"outbound_in_window", "last_outbound_at", "days_since_outbound", "status"})
+
+	for _, item := range items {
+		lastOutbound, daysSince := "", ""
+		if item.LastOutboundAt != nil {
+			lastOutbound = item.LastOutboundAt.Format(time.RFC3339)
+			daysSince = strconv.Itoa(*item.DaysSinceOutbound)
+		}
+
+		w.Write([]string{
+			strconv.FormatInt(item.ProductID, 10),
+			csvSafe(item.Name),
+			csvSafe(item.Category),
+			strconv.FormatFloat(item.Price, 'f', 2, 64),
+			strconv.Itoa(item.Stock),
+			strconv.FormatFloat(item.Value, 'f', 2, 64),
+			strconv.Itoa(item.OutboundInWindow),
+			lastOutbound,
+			daysSince,
+			item.Status,
+		})
+	}
+	w.Flush()
+}
@@ -2,20 +2,65 @@ package routes
 
 import (
 	"stock-dashboard/middleware"
+	"stock-dashboard/models"
 
 	"github.com/gin-gonic/gin"
 )
 
+// apiKeyScopes lists the only routes API keys may call and the permission
+// each one needs. Anything missing here is closed to API keys.
+var apiKeyScopes = map[string]string{
+	"GET /api/events/stream":               models.PermEventsRead,
+	"GET /api/autocomplete":                models.PermProductsRead,
+	"GET /api/products/":                   models.PermProductsRead,
+	"GET /api/products/search":             models.PermProductsRead,
+	"GET /api/products/:id":                models.PermProductsRead,
+	"POST /api/products/":                  models.PermProductsWrite,
+	"POST /api/products/bulk":              models.PermProductsWrite,
+	"PUT /api/products/:id":                models.PermProductsWrite,
+	"DELETE /api/products/:id":             models.PermProductsWrite,
+	"GET /api/reports/reorder-suggestions": models.PermReportsRead,
+	"GET /api/reports/abc-xyz":             models.PermReportsRead,
+	"GET /api/reports/dead-stock":          models.PermReportsRead,
+}
+
 func RegisterRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", GetJWKS)
 
 	api := router.Group("/api")
 	{
 		api.POST("/login", LoginHandler)
+		api.POST("/login/mfa", LoginMFA)
 		api.POST("/register", RegisterHandler)
+		api.POST("/password/forgot", ForgotPassword)
+		api.POST("/password/reset", ResetPassword)
+		api.POST("/invitations/accept", AcceptInvitation)
+
+		api.GET("/auth/providers", GetAuthProviders)
+		api.GET("/auth/oidc/login", OIDCLogin)
+		api.GET("/auth/oidc/callback", OIDCCallback)
+
+		api.GET("/events/stream", middleware.TokenFromQuery, middleware.JWTAuthMiddleware,
+			middleware.APIKeyScopes(apiKeyScopes), StreamEvents)
 
 		protected := api.Group("/")
-		protected.Use(middleware.JWTAuthMiddleware)
+		protected.Use(middleware.JWTAuthMiddleware, middleware.APIKeyScopes(apiKeyScopes))
 		{
+			protected.GET("/autocomplete", Autocomplete)
+
+			me := protected.Group("/me")
+			{
+				me.GET("", GetMe)
+				me.PATCH("", UpdateMe)
+				me.POST("/password", ChangeMyPassword)
+				me.GET("/mfa", GetMFAStatus)
+				me.POST("/mfa/setup", SetupMFA)
+				me.POST("/mfa/enable", EnableMFA)
+				me.POST("/mfa/disable", DisableMFA)
+				me.POST("/mfa/recovery-codes", RegenerateRecoveryCodes)
+				me.GET("/sessions", GetMySessions)
+				me.DELETE("/sessions/:id", RevokeMySession)
+			}
 
 			products := protected.Group("/products")
 			{
@@ -23,15 +68,77 @@ func RegisterRoutes(router *gin.Engine) {
 				products.POST("/", CreateProduct)
 
 				products.GET("/search", SearchProducts)
+				products.POST("/bulk", BulkProducts)
+				products.GET("/trash", GetTrashedProducts)
+				products.DELETE("/trash", middleware.AdminOnly(), PurgeTrashedProducts)
 
 				products.GET("/:id", GetProduct)
 				products.PUT("/:id", UpdateProduct)
 				products.DELETE("/:id", DeleteProduct)
+				products.POST("/:id/restore", RestoreProduct)
+			}
+			protected.GET("/audit", middleware.AdminOnly(), GetAuditLogs)
+			protected.POST("/auth/keys/rotate", middleware.AdminOnly(), RotateSigningKey)
+
+			views := protected.Group("/views")
+			{
+				views.GET("/", GetSavedViews)
+				views.POST("/", CreateSavedView)
+				views.GET("/:id", GetSavedView)
+				views.PUT("/:id", UpdateSavedView)
+				views.DELETE("/:id", DeleteSavedView)
+			}
+			webhooks := protected.Group("/webhooks")
+			webhooks.Use(middleware.AdminOnly())
+			{
+				webhooks.GET("/", GetWebhooks)
+				webhooks.POST("/", CreateWebhook)
+				webhooks.GET("/:id", GetWebhook)
+				webhooks.PUT("/:id", UpdateWebhook)
+				webhooks.DELETE("/:id", DeleteWebhook)
+				webhooks.GET("/:id/deliveries", GetWebhookDeliveries)
+				webhooks.POST("/:id/deliveries/:deliveryId/redeliver", RedeliverWebhook)
+			}
+			users := protected.Group("/users")
+			users.Use(middleware.AdminOnly())
+			{
+				users.GET("/", GetUsers)
+				users.GET("/:id", GetUser)
+				users.PUT("/:id", UpdateUser)
+				users.POST("/:id/disable", DisableUser)
+				users.POST("/:id/enable", EnableUser)
+				users.POST("/:id/unlock", UnlockUser)
+				users.POST("/:id/mfa/reset", ResetUserMFA)
+			}
+			invitations := protected.Group("/invitations")
+			invitations.Use(middleware.AdminOnly())
+			{
+				invitations.GET("/", GetInvitations)
+				invitations.POST("/", CreateInvitation)
+				invitations.DELETE("/:id", RevokeInvitation)
+			}
+			apiKeys := protected.Group("/api-keys")
+			apiKeys.Use(middleware.AdminOnly())
+			{
+				apiKeys.GET("/", GetAPIKeys)
+				apiKeys.POST("/", CreateAPIKey)
+				apiKeys.GET("/:id", GetAPIKey)
+				apiKeys.PUT("/:id", UpdateAPIKey)
+				apiKeys.DELETE("/:id", RevokeAPIKey)
 			}
 			staff := protected.Group("/staff")
 			{
 				staff.GET("/", GetAllStaff)
-				staff.DELETE("/:id", DeleteStaff)
+				staff.DELETE("/:id", middleware.AdminOnly(), DeleteStaff)
+				staff.GET("/:id/sessions", middleware.AdminOnly(), GetStaffSessions)
+				staff.DELETE("/:id/sessions", middleware.AdminOnly(), RevokeStaffSessions)
+			}
+			reports := protected.Group("/reports")
+			{
+				reports.GET("/reorder-suggestions", GetReorderSuggestions)
+				reports.GET("/abc-xyz", GetInventoryClassification)
+				reports.GET("/dead-stock", GetDeadStock)
+				reports.POST("/abc-xyz/recalculate", middleware.AdminOnly(), RecalculateInventoryClassification)
 			}
 		}
 	}
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"stock-dashboard/models"
+	"stock-dashboard/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// issueSessionToken records a new session for the request's device and
+// returns an access token bound to it.
+func issueSessionToken(c *gin.Context, user *models.User, tokenVersion int, mfa bool, method string) (string, error) {
+	session := models.Session{
+		UserID:    user.ID,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		Method:    method,
+	}
+	err := session.Save(tokenVersion)
+	if err != nil {
+		return "", err
+	}
+	return utils.GenerateToken(user.Email, user.ID, user.Role, tokenVersion, mfa, session.ID)
+}
+
+func GetMySessions(c *gin.Context) {
+	sessions, err := models.GetActiveSessions(c.GetString("userID"))
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch sessions")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	current := c.GetString("sessionID")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	response := models.NewSuccessResponse(sessions, "Sessions fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RevokeMySession(c *gin.Context) {
+	userID := c.GetString("userID")
+	sessionID := c.Param("id")
+
+	err := models.RevokeSession(sessionID, userID)
+	if errors.Is(err, models.ErrSessionNotFound) {
+		response := models.NewErrorResponse("Session not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to sign out session")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditRevoke, "session", sessionID, gin.H{"userId": userID}, nil)
+
+	response := models.NewSuccessResponse(nil, "Session signed out successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetStaffSessions(c *gin.Context) {
+	user := models.User{ID: c.Param("id")}
+	err := user.Get()
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			response := models.NewErrorResponse("Staff member not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to fetch staff member")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	sessions, err := models.GetActiveSessions(user.ID)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch sessions")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := models.NewSuccessResponse(sessions, "Sessions fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RevokeStaffSessions(c *gin.Context) {
+	staffID := c.Param("id")
+
+	revoked, err := models.RevokeAllSessions(staffID)
+	if errors.Is(err, models.ErrUserNotFound) {
+		response := models.NewErrorResponse("Staff member not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to sign out staff member")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditRevoke, "user_sessions", staffID, nil, gin.H{"revoked": revoked})
+
+	data := gin.H{"revoked": revoked}
+	response := models.NewSuccessResponse(data, "All sessions signed out successfully")
+	c.JSON(http.StatusOK, response)
+}
@@ -1,14 +1,39 @@
 package routes
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"stock-dashboard/models"
 	"stock-dashboard/utils"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Failed logins are tracked per client IP and per email. Both back off
+// progressively; the per-account lockout itself is stored on the user so it
+// holds across instances.
+var (
+	ipLoginFailures    = utils.NewFailureTracker(10, 15*time.Minute, time.Hour)
+	emailLoginFailures = utils.NewFailureTracker(3, time.Minute, 15*time.Minute)
+)
+
+func tooManyLoginAttempts(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	response := models.NewErrorResponse("Too many failed login attempts, please try again later")
+	c.JSON(http.StatusTooManyRequests, response)
+}
+
 func LoginHandler(c *gin.Context) {
+	if !passwordLoginEnabled() {
+		response := models.NewErrorResponse("Password login is disabled, use single sign-on")
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
+
 	var user models.User
 
 	err := c.ShouldBindJSON(&user)
@@ -19,14 +44,53 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
+	ipKey := c.ClientIP()
+	emailKey := strings.ToLower(user.Email)
+	if wait := max(ipLoginFailures.RetryAfter(ipKey), emailLoginFailures.RetryAfter(emailKey)); wait > 0 {
+		tooManyLoginAttempts(c, wait)
+		return
+	}
+
 	err = user.ValidateCredentials()
 
+	if errors.Is(err, models.ErrInvalidLogin) {
+		ipLoginFailures.Fail(ipKey)
+		emailLoginFailures.Fail(emailKey)
+	}
+	if err == nil {
+		emailLoginFailures.Reset(emailKey)
+	}
+
+	if errors.Is(err, models.ErrUserDisabled) {
+		response := models.NewErrorResponse("Account is disabled")
+		c.JSON(http.StatusForbidden, response)
+		return
+	}
 	if err != nil {
 		response := models.NewErrorResponse("Invalid email or password")
 		c.JSON(http.StatusUnauthorized, response)
 		return
 	}
-	token, err := utils.GenerateToken(user.Email, user.ID, user.Role)
+	// With two-factor enabled the password only earns a challenge token,
+	// which POST /api/login/mfa exchanges for an access token.
+	if user.MFA {
+		mfaToken, err := utils.GenerateMFAToken(user.ID, user.TokenVersion)
+		if err != nil {
+			response := models.NewErrorResponse("Could not generate token")
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		data := gin.H{
+			"mfaRequired": true,
+			"mfaToken":    mfaToken,
+		}
+		response := models.NewSuccessResponse(data, "Two-factor authentication required")
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := issueSessionToken(c, &user, user.TokenVersion, false, models.SessionPassword)
 
 	if err != nil {
 		response := models.NewErrorResponse("Could not generate token")
@@ -45,9 +109,25 @@ func LoginHandler(c *gin.Context) {
 
 }
 
+type registerRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Name     string `json:"name" binding:"max=255"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
 func RegisterHandler(context *gin.Context) {
-	var user models.User
-	err := context.ShouldBindJSON(&user)
+	if mode := models.RegistrationMode(); mode != models.RegistrationOpen || !passwordLoginEnabled() {
+		message := "Registration is closed"
+		if mode == models.RegistrationInvite {
+			message = "Registration requires an invitation"
+		}
+		response := models.NewErrorResponse(message)
+		context.JSON(http.StatusForbidden, response)
+		return
+	}
+
+	var request registerRequest
+	err := context.ShouldBindJSON(&request)
 
 	if err != nil {
 		response := models.NewErrorResponse("Could not parse request data")
@@ -55,7 +135,15 @@ func RegisterHandler(context *gin.Context) {
 		return
 	}
 
+	// Public sign-up always creates staff; admins are made through
+	// invitations or the user management API.
+	user := models.User{Email: request.Email, Name: request.Name, Password: request.Password}
 	err = user.Save()
+	if errors.Is(err, models.ErrEmailTaken) {
+		response := models.NewErrorResponse("Email is already in use")
+		context.JSON(http.StatusConflict, response)
+		return
+	}
 	if err != nil {
 		response := models.NewErrorResponse("Could not save user")
 		context.JSON(http.StatusInternalServerError, response)
@@ -67,6 +155,8 @@ func RegisterHandler(context *gin.Context) {
 		"email":   user.Email,
 		"role":    user.Role,
 	}
+	recordAudit(context, AuditCreate, "user", user.ID, nil, data)
+
 	response := models.NewSuccessResponse(data, "User created successfully")
 	context.JSON(http.StatusCreated, response)
 }
@@ -91,6 +181,9 @@ func DeleteStaff(c *gin.Context) {
 		return
 	}
 
+	before := models.User{ID: staffID}
+	before.Get()
+
 	user := models.User{ID: staffID}
 	err := user.Delete()
 
@@ -105,6 +198,184 @@ func DeleteStaff(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, AuditDelete, "user", staffID, gin.H{"id": before.ID, "email": before.Email, "role": before.Role}, nil)
+
 	response := models.NewSuccessResponse(nil, "Staff member deleted successfully")
 	c.JSON(http.StatusOK, response)
 }
+
+func userSnapshot(u models.User) gin.H {
+	return gin.H{"id": u.ID, "email": u.Email, "name": u.Name, "role": u.Role, "disabled": u.Disabled}
+}
+
+func GetUsers(c *gin.Context) {
+	filter := models.UserFilter{
+		Search: c.Query("search"),
+		Role:   c.Query("role"),
+	}
+
+	if filter.Role != "" && filter.Role != "admin" && filter.Role != "staff" {
+		response := models.NewErrorResponse("Role must be admin or staff")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if disabled := c.Query("disabled"); disabled != "" {
+		value, err := strconv.ParseBool(disabled)
+		if err != nil {
+			response := models.NewErrorResponse("Invalid disabled filter")
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		filter.Disabled = &value
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 200 {
+		filter.Limit = limit
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+
+	result, err := models.GetUsers(filter)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch users")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"users":      result.Users,
+		"count":      len(result.Users),
+		"page":       result.Page,
+		"limit":      result.Limit,
+		"total":      result.Total,
+		"totalPages": result.TotalPages,
+	}
+	response := models.NewSuccessResponse(data, "Users fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetUser(c *gin.Context) {
+	user := models.User{ID: c.Param("id")}
+	err := user.Get()
+	if errors.Is(err, models.ErrUserNotFound) {
+		response := models.NewErrorResponse("User not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := models.NewSuccessResponse(user, "User fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func UpdateUser(c *gin.Context) {
+	var update models.UserUpdate
+	err := c.ShouldBindJSON(&update)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request data: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	update.ID = c.Param("id")
+
+	applyUserUpdate(c, update, "User updated successfully")
+}
+
+func DisableUser(c *gin.Context) {
+	disabled := true
+	applyUserUpdate(c, models.UserUpdate{ID: c.Param("id"), Disabled: &disabled}, "User disabled successfully")
+}
+
+func UnlockUser(c *gin.Context) {
+	user := models.User{ID: c.Param("id")}
+	err := user.Unlock()
+	if errors.Is(err, models.ErrUserNotFound) {
+		response := models.NewErrorResponse("User not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to unlock user")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	err = user.Get()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	emailLoginFailures.Reset(strings.ToLower(user.Email))
+
+	recordAudit(c, AuditUnlock, "user", user.ID, nil, userSnapshot(user))
+
+	response := models.NewSuccessResponse(user, "User unlocked successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func EnableUser(c *gin.Context) {
+	disabled := false
+	applyUserUpdate(c, models.UserUpdate{ID: c.Param("id"), Disabled: &disabled}, "User enabled successfully")
+}
+
+func applyUserUpdate(c *gin.Context, update models.UserUpdate, message string) {
+	// Admins cannot lock themselves out by accident.
+	if update.ID == c.GetString("userID") && ((update.Role != "" && update.Role != "admin") ||
+		(update.Disabled != nil && *update.Disabled)) {
+		response := models.NewErrorResponse("You cannot demote or disable your own account")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	before := models.User{ID: update.ID}
+	err := before.Get()
+	if errors.Is(err, models.ErrUserNotFound) {
+		response := models.NewErrorResponse("User not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	err = update.Update()
+	if errors.Is(err, models.ErrUserNotFound) {
+		response := models.NewErrorResponse("User not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+	if errors.Is(err, models.ErrEmailTaken) {
+		response := models.NewErrorResponse("Email is already in use")
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	if err != nil {
+		response := models.NewErrorResponse("Failed to update user")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	after := models.User{ID: update.ID}
+	err = after.Get()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	auditAfter := userSnapshot(after)
+	if update.Password != "" {
+		auditAfter["password_changed"] = true
+	}
+	recordAudit(c, AuditUpdate, "user", after.ID, userSnapshot(before), auditAfter)
+
+	response := models.NewSuccessResponse(after, message)
+	c.JSON(http.StatusOK, response)
+}
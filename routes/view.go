@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"stock-dashboard/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetSavedViews(c *gin.Context) {
+	views, err := models.GetSavedViews(c.GetString("userID"))
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch saved views")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"views": views,
+		"count": len(views),
+	}
+	response := models.NewSuccessResponse(data, "Saved views fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetSavedView(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid view ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	view := models.SavedView{ID: id}
+	err = view.Get(c.GetString("userID"))
+	if err != nil {
+		response := models.NewErrorResponse("Saved view not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	data := gin.H{
+		"view": view,
+	}
+	response := models.NewSuccessResponse(data, "Saved view fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateSavedView(c *gin.Context) {
+	var view models.SavedView
+
+	err := c.ShouldBindJSON(&view)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request format")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = view.Validate()
+	if err != nil {
+		response := models.NewErrorResponse("Invalid saved view: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	view.UserID = c.GetString("userID")
+	err = view.Save()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to create saved view")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditCreate, "saved_view", strconv.FormatInt(view.ID, 10), nil, view)
+
+	data := gin.H{
+		"view": view,
+	}
+	response := models.NewSuccessResponse(data, "Saved view created successfully")
+	c.JSON(http.StatusCreated, response)
+}
+
+func UpdateSavedView(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid view ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	var view models.SavedView
+	err = c.ShouldBindJSON(&view)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request format")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = view.Validate()
+	if err != nil {
+		response := models.NewErrorResponse("Invalid saved view: " + err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	before := models.SavedView{ID: id}
+	before.Get(c.GetString("userID"))
+
+	view.ID = id
+	view.UserID = c.GetString("userID")
+	err = view.Update()
+	if err != nil {
+		if errors.Is(err, models.ErrViewNotFound) {
+			response := models.NewErrorResponse("Saved view not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to update saved view")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditUpdate, "saved_view", c.Param("id"), before, view)
+
+	data := gin.H{
+		"view": view,
+	}
+	response := models.NewSuccessResponse(data, "Saved view updated successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func DeleteSavedView(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid view ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	view := models.SavedView{ID: id, UserID: c.GetString("userID")}
+	err = view.Delete()
+	if err != nil {
+		if errors.Is(err, models.ErrViewNotFound) {
+			response := models.NewErrorResponse("Saved view not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to delete saved view")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditDelete, "saved_view", c.Param("id"), gin.H{"id": id}, nil)
+
+	data := gin.H{
+		"view_id": id,
+	}
+	response := models.NewSuccessResponse(data, "Saved view deleted successfully")
+	c.JSON(http.StatusOK, response)
+}
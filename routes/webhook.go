@@ -0,0 +1,252 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"stock-dashboard/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events" binding:"required,min=1"`
+	Active *bool    `json:"active"`
+}
+
+func (r webhookRequest) toWebhook() models.Webhook {
+	webhook := models.Webhook{URL: r.URL, Secret: r.Secret, Events: r.Events, Active: true}
+	if r.Active != nil {
+		webhook.Active = *r.Active
+	}
+	return webhook
+}
+
+func GetWebhooks(c *gin.Context) {
+	webhooks, err := models.GetWebhooks()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch webhooks")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"webhooks": webhooks,
+		"count":    len(webhooks),
+	}
+	response := models.NewSuccessResponse(data, "Webhooks fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid webhook ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	webhook := models.Webhook{ID: id}
+	err = webhook.Get()
+	if err != nil {
+		response := models.NewErrorResponse("Webhook not found")
+		c.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	data := gin.H{
+		"webhook": webhook,
+	}
+	response := models.NewSuccessResponse(data, "Webhook fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateWebhook(c *gin.Context) {
+	var request webhookRequest
+
+	err := c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request format")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	webhook := request.toWebhook()
+	err = webhook.Validate()
+	if err != nil {
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	createdBy := c.GetString("userID")
+	webhook.CreatedBy = &createdBy
+	err = webhook.Save()
+	if err != nil {
+		response := models.NewErrorResponse("Failed to create webhook")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditCreate, "webhook", strconv.FormatInt(webhook.ID, 10), nil,
+		gin.H{"url": webhook.URL, "events": webhook.Events, "active": webhook.Active})
+
+	// The secret is only ever returned here, when the webhook is created.
+	data := gin.H{
+		"webhook": webhook,
+	}
+	response := models.NewSuccessResponse(data, "Webhook created successfully")
+	c.JSON(http.StatusCreated, response)
+}
+
+func UpdateWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid webhook ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	var request webhookRequest
+	err = c.ShouldBindJSON(&request)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid request format")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	webhook := request.toWebhook()
+	err = webhook.Validate()
+	if err != nil {
+		response := models.NewErrorResponse(err.Error())
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	before := models.Webhook{ID: id}
+	before.Get()
+
+	webhook.ID = id
+	err = webhook.Update()
+	if err != nil {
+		if errors.Is(err, models.ErrWebhookNotFound) {
+			response := models.NewErrorResponse("Webhook not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to update webhook")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	rotated := webhook.Secret != ""
+	webhook.Secret = ""
+	recordAudit(c, AuditUpdate, "webhook", c.Param("id"), before, gin.H{
+		"id": webhook.ID, "url": webhook.URL, "events": webhook.Events, "active": webhook.Active, "secretRotated": rotated,
+	})
+
+	data := gin.H{
+		"webhook": webhook,
+	}
+	response := models.NewSuccessResponse(data, "Webhook updated successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid webhook ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	before := models.Webhook{ID: id}
+	before.Get()
+
+	webhook := models.Webhook{ID: id}
+	err = webhook.Delete()
+	if err != nil {
+		if errors.Is(err, models.ErrWebhookNotFound) {
+			response := models.NewErrorResponse("Webhook not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to delete webhook")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditDelete, "webhook", c.Param("id"), before, nil)
+
+	data := gin.H{
+		"webhook_id": id,
+	}
+	response := models.NewSuccessResponse(data, "Webhook deleted successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid webhook ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	deliveries, err := models.GetWebhookDeliveries(id, c.Query("status"), limit)
+	if err != nil {
+		response := models.NewErrorResponse("Failed to fetch webhook deliveries")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	data := gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	}
+	response := models.NewSuccessResponse(data, "Webhook deliveries fetched successfully")
+	c.JSON(http.StatusOK, response)
+}
+
+func RedeliverWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid webhook ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		response := models.NewErrorResponse("Invalid delivery ID")
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = models.RedeliverWebhook(id, deliveryID)
+	if err != nil {
+		if errors.Is(err, models.ErrDeliveryNotFound) {
+			response := models.NewErrorResponse("Webhook delivery not found")
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		response := models.NewErrorResponse("Failed to schedule redelivery")
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	recordAudit(c, AuditRedeliver, "webhook_delivery", c.Param("deliveryId"), nil, gin.H{"webhookId": id})
+
+	data := gin.H{
+		"delivery_id": deliveryID,
+	}
+	response := models.NewSuccessResponse(data, "Webhook delivery scheduled for redelivery")
+	c.JSON(http.StatusAccepted, response)
+}
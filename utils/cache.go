@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+type TTLCache[V any] struct {
+	mu         sync.RWMutex
+	items      map[string]cacheEntry[V]
+	ttl        time.Duration
+	maxEntries int
+}
+
+func NewTTLCache[V any](ttl time.Duration, maxEntries int) *TTLCache[V] {
+	return &TTLCache[V]{
+		items:      make(map[string]cacheEntry[V]),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *TTLCache[V]) Get(key string) (V, bool) {
+	c.mu.RLock()
+	entry, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *TTLCache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.items[key]; !exists && len(c.items) >= c.maxEntries {
+		c.evict()
+	}
+	c.items[key] = cacheEntry[V]{value: value, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *TTLCache[V]) Clear() {
+	c.mu.Lock()
+	c.items = make(map[string]cacheEntry[V])
+	c.mu.Unlock()
+}
+
+// evict drops expired entries, and if the cache is still full, an arbitrary
+// one. Callers must hold the write lock.
+func (c *TTLCache[V]) evict() {
+	now := time.Now()
+	for key, entry := range c.items {
+		if now.After(entry.expiresAt) {
+			delete(c.items, key)
+		}
+	}
+
+	for key := range c.items {
+		if len(c.items) < c.maxEntries {
+			break
+		}
+		delete(c.items, key)
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testCursor struct {
+	ID    int64  `json:"id"`
+	Value string `json:"value"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "cursor-secret")
+
+	cursor, err := EncodeCursor(testCursor{ID: 42, Value: "Green Tea"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got testCursor
+	if err := DecodeCursor(cursor, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (testCursor{ID: 42, Value: "Green Tea"}) {
+		t.Errorf("DecodeCursor() = %+v", got)
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "cursor-secret")
+
+	cursor, err := EncodeCursor(testCursor{ID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, signature, _ := strings.Cut(cursor, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"id":1}`))
+	garbage := base64.RawURLEncoding.EncodeToString([]byte(`not json`))
+	garbageSignature, err := signCursor(garbage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"empty signature", payload + "."},
+		{"swapped payload", forged + "." + signature},
+		{"truncated signature", payload + "." + signature[:len(signature)-1]},
+		{"signed garbage", garbage + "." + garbageSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testCursor
+			if err := DecodeCursor(tt.cursor, &got); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		t.Setenv("CURSOR_SECRET", "rotated-secret")
+		var got testCursor
+		if err := DecodeCursor(cursor, &got); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor() error = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+func TestCursorSecret(t *testing.T) {
+	t.Setenv("CURSOR_SECRET", "")
+	t.Setenv("JWT_SECRET", "jwt-secret")
+
+	t.Setenv("MFA_ENCRYPTION_KEY", "")
+	if _, err := EncodeCursor(testCursor{ID: 1}); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("EncodeCursor() error = %v, want ErrNoEncryptionKey", err)
+	}
+
+	t.Setenv("MFA_ENCRYPTION_KEY", "encryption-key")
+	derived, err := cursorSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range []string{"jwt-secret", "encryption-key"} {
+		if string(derived) == other {
+			t.Errorf("cursor key is the same as %q", other)
+		}
+	}
+	aesKey, _ := secretKey()
+	if string(derived) == string(aesKey) {
+		t.Error("cursor key is the same as the encryption key")
+	}
+
+	cursor, err := EncodeCursor(testCursor{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got testCursor
+	if err := DecodeCursor(cursor, &got); err != nil || got.ID != 1 {
+		t.Errorf("DecodeCursor() = %+v, %v", got, err)
+	}
+}
@@ -12,16 +12,41 @@ type CustomClaims struct {
 	UserID string `json:"user_id"`
 	Role   string `json:"role"`
 	Email  string `json:"email"`
+	// TokenVersion must match users.token_version; bumping it revokes every
+	// token issued before.
+	TokenVersion int `json:"tv"`
+	// MFA is set when the session was established with a second factor.
+	MFA bool `json:"mfa,omitempty"`
+	// Purpose marks restricted tokens, such as the MFA challenge, that are
+	// not valid as access tokens.
+	Purpose string `json:"purpose,omitempty"`
+	// SessionID ties the token to a row in sessions so it can be signed out
+	// remotely.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+const PurposeMFA = "mfa"
+
 func VerifyToken(tokenStr string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			// Tokens signed with the shared secret keep working until
+			// JWT_ACCEPT_HS256_UNTIL, so switching algorithms logs no one out.
+			secret := os.Getenv("JWT_SECRET")
+			if secret == "" || !acceptHS256() {
+				return nil, errors.New("unexpected signing method")
+			}
+			return []byte(secret), nil
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := verificationKey(kid)
+		if !ok || key.Algorithm != t.Method.Alg() {
+			return nil, errors.New("unknown signing key")
 		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+		return key.Private.Public(), nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA, AlgHS256}))
 
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid token")
@@ -33,16 +58,70 @@ func VerifyToken(tokenStr string) (*CustomClaims, error) {
 	return claims, nil
 }
 
-func GenerateToken(userEmail string, userID string, role string) (string, error) {
+func GenerateToken(userEmail string, userID string, role string, tokenVersion int, mfa bool, sessionID string) (string, error) {
 	claim := CustomClaims{
-		UserID: userID,
-		Role:   role,
-		Email:  userEmail,
+		UserID:       userID,
+		Role:         role,
+		Email:        userEmail,
+		TokenVersion: tokenVersion,
+		MFA:          mfa,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		}}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-	secret := os.Getenv("JWT_SECRET")
-	return token.SignedString([]byte(secret))
+	return signClaims(claim)
+}
+
+// GenerateMFAToken issues the short-lived challenge token handed out after
+// the password step; it can only be exchanged for an access token.
+func GenerateMFAToken(userID string, tokenVersion int) (string, error) {
+	claim := CustomClaims{
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		Purpose:      PurposeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		}}
+	return signClaims(claim)
+}
+
+// HS256AcceptedUntil parses JWT_ACCEPT_HS256_UNTIL, an RFC 3339 time or a
+// date. It returns the zero time when the variable is unset.
+func HS256AcceptedUntil() (time.Time, error) {
+	raw := os.Getenv("JWT_ACCEPT_HS256_UNTIL")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if until, err := time.Parse(time.RFC3339, raw); err == nil {
+		return until, nil
+	}
+	return time.ParseInLocation(time.DateOnly, raw, time.Local)
+}
+
+// acceptHS256 reports whether tokens signed with JWT_SECRET still verify.
+// They always do while HS256 is the signing algorithm; after moving to an
+// asymmetric key they are only accepted until the configured cutoff.
+func acceptHS256() bool {
+	if _, ok := activeSigningKey(); !ok {
+		return true
+	}
+	until, err := HS256AcceptedUntil()
+	return err == nil && time.Now().Before(until)
+}
+
+// signClaims signs with the active key from the keyring, falling back to
+// HS256 and JWT_SECRET when no asymmetric key is configured.
+func signClaims(claim CustomClaims) (string, error) {
+	key, ok := activeSigningKey()
+	if !ok {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
+		secret := os.Getenv("JWT_SECRET")
+		return token.SignedString([]byte(secret))
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claim)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.Private)
 }
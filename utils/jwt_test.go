@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useSigningKeys installs keys in the keyring for the duration of the test.
+func useSigningKeys(t *testing.T, keys ...SigningKey) {
+	t.Helper()
+	SetSigningKeys(keys)
+	t.Cleanup(func() {
+		SetSigningKeys(nil)
+		SetKeyRefresher(nil)
+		keyring.mu.Lock()
+		keyring.lastRefresh = time.Time{}
+		keyring.mu.Unlock()
+	})
+}
+
+// newSigningKey returns a key that signs from createdAt, or one that was
+// retired the moment it was created when active is false.
+func newSigningKey(t *testing.T, kid, algorithm string, createdAt time.Time, active bool) SigningKey {
+	t.Helper()
+	private, err := GenerateSigningKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := SigningKey{Kid: kid, Algorithm: algorithm, Private: private, CreatedAt: createdAt, ActivatesAt: createdAt}
+	if !active {
+		key.RetiredAt = &createdAt
+	}
+	return key
+}
+
+func hs256Token(t *testing.T, secret string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, CustomClaims{
+		UserID: "1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		keys []SigningKey
+		alg  string
+	}{
+		{"shared secret", nil, AlgHS256},
+		{"RS256", []SigningKey{newSigningKey(t, "rsa", AlgRS256, now, true)}, AlgRS256},
+		{"EdDSA", []SigningKey{newSigningKey(t, "ed", AlgEdDSA, now, true)}, AlgEdDSA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "test-secret")
+			useSigningKeys(t, tt.keys...)
+
+			signed, err := GenerateToken("a@example.com", "1", "admin", 3, true, "sid")
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(signed, &CustomClaims{})
+			if err != nil || parsed.Method.Alg() != tt.alg {
+				t.Fatalf("token signed with %v, %v; want %s", parsed, err, tt.alg)
+			}
+
+			claims, err := VerifyToken(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != "1" || claims.Role != "admin" || claims.TokenVersion != 3 || !claims.MFA || claims.SessionID != "sid" {
+				t.Errorf("VerifyToken() = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenHS256Cutoff(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  bool
+		until string
+		want  bool
+	}{
+		{"signing with the shared secret", false, "", true},
+		{"asymmetric key, no cutoff", true, "", false},
+		{"asymmetric key, cutoff ahead", true, time.Now().Add(time.Hour).Format(time.RFC3339), true},
+		{"asymmetric key, cutoff date ahead", true, time.Now().AddDate(0, 0, 2).Format(time.DateOnly), true},
+		{"asymmetric key, cutoff passed", true, time.Now().Add(-time.Hour).Format(time.RFC3339), false},
+		{"asymmetric key, invalid cutoff", true, "soon", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "test-secret")
+			t.Setenv("JWT_ACCEPT_HS256_UNTIL", tt.until)
+			if tt.keys {
+				useSigningKeys(t, newSigningKey(t, "rsa", AlgRS256, time.Now(), true))
+			} else {
+				useSigningKeys(t)
+			}
+
+			_, err := VerifyToken(hs256Token(t, "test-secret"))
+			if (err == nil) != tt.want {
+				t.Errorf("VerifyToken() error = %v, want accepted %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("wrong secret", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "test-secret")
+		useSigningKeys(t)
+		if _, err := VerifyToken(hs256Token(t, "other-secret")); err == nil {
+			t.Error("VerifyToken() accepted a token signed with another secret")
+		}
+	})
+}
+
+func TestKeyring(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	old := newSigningKey(t, "old", AlgRS256, now.Add(-48*time.Hour), true)
+	current := newSigningKey(t, "current", AlgEdDSA, now.Add(-time.Hour), true)
+	retired := newSigningKey(t, "retired", AlgRS256, now.Add(-72*time.Hour), false)
+	expiredKey := newSigningKey(t, "expired", AlgRS256, now.Add(-96*time.Hour), false)
+	expiredKey.ExpiresAt = &past
+	useSigningKeys(t, old, current, retired, expiredKey)
+
+	if key, ok := activeSigningKey(); !ok || key.Kid != "current" {
+		t.Errorf("active key = %q, want the newest active key %q", key.Kid, "current")
+	}
+
+	tests := []struct {
+		kid  string
+		want bool
+	}{
+		{"current", true},
+		{"old", true},
+		{"retired", true},
+		{"expired", false},
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		if _, ok := verificationKey(tt.kid); ok != tt.want {
+			t.Errorf("verificationKey(%q) = %v, want %v", tt.kid, ok, tt.want)
+		}
+	}
+}
+
+func TestKeyringActivation(t *testing.T) {
+	now := time.Now()
+	handover := now.Add(10 * time.Minute)
+	current := newSigningKey(t, "current", AlgRS256, now.Add(-time.Hour), true)
+	current.RetiredAt = &handover
+	next := newSigningKey(t, "next", AlgRS256, now, true)
+	next.ActivatesAt = handover
+	useSigningKeys(t, current, next)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"before handover", now, "current"},
+		{"at handover", handover, "next"},
+		{"after handover", handover.Add(time.Hour), "next"},
+	}
+	for _, tt := range tests {
+		if current.signingAt(tt.at) == next.signingAt(tt.at) {
+			t.Errorf("%s: exactly one key should sign", tt.name)
+		}
+		if next.signingAt(tt.at) != (tt.want == "next") {
+			t.Errorf("%s: next signing = %v, want %v", tt.name, next.signingAt(tt.at), tt.want == "next")
+		}
+	}
+
+	if key, ok := activeSigningKey(); !ok || key.Kid != "current" {
+		t.Errorf("active key = %q, want %q until the handover", key.Kid, "current")
+	}
+	published := false
+	for _, jwk := range PublicJWKS() {
+		published = published || jwk.Kid == "next"
+	}
+	if !published {
+		t.Error("the next key is not published before it starts signing")
+	}
+	if _, ok := verificationKey("next"); !ok {
+		t.Error("the next key does not verify before it starts signing")
+	}
+}
+
+func TestKeyringRefreshesUnknownKid(t *testing.T) {
+	useSigningKeys(t)
+	rotated := newSigningKey(t, "rotated", AlgRS256, time.Now(), true)
+
+	refreshes := 0
+	SetKeyRefresher(func() error {
+		refreshes++
+		SetSigningKeys([]SigningKey{rotated})
+		return nil
+	})
+
+	if _, ok := verificationKey("rotated"); !ok {
+		t.Fatal("verificationKey() did not pick up a key rotated in elsewhere")
+	}
+	verificationKey("forged")
+	if refreshes != 1 {
+		t.Errorf("ring refreshed %d times, want 1 within the refresh interval", refreshes)
+	}
+}
+
+func TestVerifyTokenRejectsKeyMismatch(t *testing.T) {
+	rsaKey := newSigningKey(t, "rsa", AlgRS256, time.Now(), true)
+	other := newSigningKey(t, "other", AlgRS256, time.Now(), true)
+	useSigningKeys(t, rsaKey)
+
+	claims := CustomClaims{RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	tests := []struct {
+		name string
+		kid  string
+		alg  jwt.SigningMethod
+		key  any
+	}{
+		{"unknown kid", "missing", jwt.SigningMethodRS256, rsaKey.Private},
+		{"signed by another key", "rsa", jwt.SigningMethodRS256, other.Private},
+		{"algorithm differs from key", "rsa", jwt.SigningMethodPS256, rsaKey.Private},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.alg, claims)
+			token.Header["kid"] = tt.kid
+			signed, err := token.SignedString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := VerifyToken(signed); err == nil {
+				t.Error("VerifyToken() accepted the token")
+			}
+		})
+	}
+}
+
+func TestPublicJWKS(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	rsaKey := newSigningKey(t, "rsa", AlgRS256, time.Now(), true)
+	edKey := newSigningKey(t, "ed", AlgEdDSA, time.Now(), false)
+	expiredKey := newSigningKey(t, "expired", AlgRS256, time.Now(), false)
+	expiredKey.ExpiresAt = &past
+	useSigningKeys(t, rsaKey, edKey, expiredKey)
+
+	jwks := make(map[string]JWK)
+	for _, jwk := range PublicJWKS() {
+		jwks[jwk.Kid] = jwk
+	}
+	if len(jwks) != 2 {
+		t.Fatalf("PublicJWKS() has %d keys, want 2 without the expired key", len(jwks))
+	}
+
+	public := rsaKey.Private.Public().(*rsa.PublicKey)
+	want := JWK{Kid: "rsa", Kty: "RSA", Alg: AlgRS256, Use: "sig",
+		N: encodeBase64BigInt(public.N), E: "AQAB"}
+	if jwks["rsa"] != want {
+		t.Errorf("RSA JWK = %+v, want %+v", jwks["rsa"], want)
+	}
+
+	x := base64.RawURLEncoding.EncodeToString(edKey.Private.Public().(ed25519.PublicKey))
+	want = JWK{Kid: "ed", Kty: "OKP", Alg: AlgEdDSA, Use: "sig", Crv: "Ed25519", X: x}
+	if jwks["ed"] != want {
+		t.Errorf("Ed25519 JWK = %+v, want %+v", jwks["ed"], want)
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+)
+
+type SigningKey struct {
+	Kid       string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+	// A key is published from the moment it is in the ring but only signs
+	// tokens from ActivatesAt until RetiredAt, so verifiers that cache our
+	// JWKS already know it by the time they see a token it signed. Retired
+	// keys stay in the ring until ExpiresAt so those tokens still verify.
+	ActivatesAt time.Time
+	RetiredAt   *time.Time
+	ExpiresAt   *time.Time
+}
+
+// JWKSMaxAge is how long clients may cache the published key set.
+const JWKSMaxAge = 5 * time.Minute
+
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// refreshInterval limits how often an unknown kid reloads the ring, which
+// is how an instance picks up a key another instance just rotated in.
+const refreshInterval = 30 * time.Second
+
+var keyring struct {
+	mu          sync.RWMutex
+	keys        map[string]SigningKey
+	refresh     func() error
+	lastRefresh time.Time
+}
+
+func GenerateSigningKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgRS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+func SetSigningKeys(keys []SigningKey) {
+	ring := make(map[string]SigningKey, len(keys))
+	for _, key := range keys {
+		ring[key.Kid] = key
+	}
+
+	keyring.mu.Lock()
+	keyring.keys = ring
+	keyring.mu.Unlock()
+}
+
+func SetKeyRefresher(refresh func() error) {
+	keyring.mu.Lock()
+	keyring.refresh = refresh
+	keyring.mu.Unlock()
+}
+
+func (k SigningKey) signingAt(t time.Time) bool {
+	return !t.Before(k.ActivatesAt) && (k.RetiredAt == nil || t.Before(*k.RetiredAt))
+}
+
+// activeSigningKey returns the most recently activated key that may sign
+// right now. It is worked out on every call so a pending key takes over at
+// its activation time without the ring being reloaded.
+func activeSigningKey() (SigningKey, bool) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	now := time.Now()
+	var active SigningKey
+	found := false
+	for _, key := range keyring.keys {
+		if key.signingAt(now) && (!found || key.ActivatesAt.After(active.ActivatesAt)) {
+			active, found = key, true
+		}
+	}
+	return active, found
+}
+
+func verificationKey(kid string) (SigningKey, bool) {
+	keyring.mu.RLock()
+	key, ok := keyring.keys[kid]
+	refresh := keyring.refresh
+	due := time.Since(keyring.lastRefresh) > refreshInterval
+	keyring.mu.RUnlock()
+
+	if ok || refresh == nil || !due {
+		return key, ok && !expired(key)
+	}
+
+	keyring.mu.Lock()
+	keyring.lastRefresh = time.Now()
+	keyring.mu.Unlock()
+	if err := refresh(); err != nil {
+		return SigningKey{}, false
+	}
+
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+	key, ok = keyring.keys[kid]
+	return key, ok && !expired(key)
+}
+
+func expired(key SigningKey) bool {
+	return key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)
+}
+
+func encodeBase64BigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// PublicJWKS returns the public half of every key that can still verify a
+// token, for publishing at /.well-known/jwks.json.
+func PublicJWKS() []JWK {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	jwks := []JWK{}
+	for _, key := range keyring.keys {
+		if expired(key) {
+			continue
+		}
+
+		jwk := JWK{Kid: key.Kid, Alg: key.Algorithm, Use: "sig"}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBase64BigInt(public.N)
+			jwk.E = encodeBase64BigInt(big.NewInt(int64(public.E)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimiter is a fixed-window counter per key.
+type RateLimiter struct {
+	Window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+func NewRateLimiter(window time.Duration) *RateLimiter {
+	return &RateLimiter{Window: window, windows: make(map[string]*rateWindow)}
+}
+
+// Allow counts a request against key and reports whether it fits within
+// limit, how many requests remain and when the window resets.
+func (r *RateLimiter) Allow(key string, limit int) (bool, int, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	w, ok := r.windows[key]
+	if !ok || now.Sub(w.start) >= r.Window {
+		if len(r.windows) >= maxTrackedKeys {
+			for k, old := range r.windows {
+				if now.Sub(old.start) >= r.Window {
+					delete(r.windows, k)
+				}
+			}
+		}
+		w = &rateWindow{start: now}
+		r.windows[key] = w
+	}
+
+	reset := w.start.Add(r.Window).Sub(now)
+	if w.count >= limit {
+		return false, 0, reset
+	}
+	w.count++
+	return true, limit - w.count, reset
+}
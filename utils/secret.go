@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+)
+
+var ErrNoEncryptionKey = errors.New("MFA_ENCRYPTION_KEY is not set")
+
+// secretKey derives the AES key from MFA_ENCRYPTION_KEY. It deliberately
+// has no fallback to JWT_SECRET, so leaking one does not expose the other.
+func secretKey() ([]byte, error) {
+	key := os.Getenv("MFA_ENCRYPTION_KEY")
+	if key == "" {
+		return nil, ErrNoEncryptionKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	return sum[:], nil
+}
+
+// DeriveKey derives a purpose-specific key from MFA_ENCRYPTION_KEY with
+// HKDF, so one configured secret can back several uses without any two of
+// them sharing a key.
+func DeriveKey(label string, length int) ([]byte, error) {
+	key := os.Getenv("MFA_ENCRYPTION_KEY")
+	if key == "" {
+		return nil, ErrNoEncryptionKey
+	}
+	return hkdf.Key(sha256.New, []byte(key), nil, "stock-dashboard "+label, length)
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := secretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret seals values such as TOTP secrets with AES-GCM before they
+// are stored.
+func EncryptSecret(plaintext string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptSecret(encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	t.Setenv("MFA_ENCRYPTION_KEY", "first-key")
+
+	sealed, err := EncryptSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := EncryptSecret("JBSWY3DPEHPK3PXP")
+	if sealed == again {
+		t.Error("EncryptSecret() reused a nonce")
+	}
+
+	plaintext, err := DecryptSecret(sealed)
+	if err != nil || plaintext != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("DecryptSecret() = %q, %v", plaintext, err)
+	}
+
+	t.Setenv("MFA_ENCRYPTION_KEY", "second-key")
+	if _, err := DecryptSecret(sealed); err == nil {
+		t.Error("DecryptSecret() opened a value sealed with another key")
+	}
+}
+
+func TestEncryptSecretRequiresKey(t *testing.T) {
+	t.Setenv("MFA_ENCRYPTION_KEY", "")
+	t.Setenv("JWT_SECRET", "jwt-secret")
+
+	if _, err := EncryptSecret("value"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("EncryptSecret() error = %v, want ErrNoEncryptionKey", err)
+	}
+	if _, err := DecryptSecret("AAAA"); !errors.Is(err, ErrNoEncryptionKey) {
+		t.Errorf("DecryptSecret() error = %v, want ErrNoEncryptionKey", err)
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "timestamp.payload".
+// Receivers recompute it with their copy of the secret and compare.
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks an X-Webhook-Signature header value in
+// constant time.
+func VerifyWebhookSignature(secret, timestamp string, payload []byte, signature string) bool {
+	expected := "sha256=" + SignWebhookPayload(secret, timestamp, payload)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
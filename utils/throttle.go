@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+type failureEntry struct {
+	count        int
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// FailureTracker counts recent failures per key. After FreeAttempts failures
+// each further one blocks the key for twice as long as the previous, up to
+// MaxDelay. Failures older than Window are forgotten. At most MaxKeys keys
+// are tracked; when full, the key that failed least recently is dropped.
+type FailureTracker struct {
+	FreeAttempts int
+	MaxDelay     time.Duration
+	Window       time.Duration
+	MaxKeys      int
+
+	mu      sync.Mutex
+	entries map[string]*failureEntry
+}
+
+const maxTrackedKeys = 10000
+
+func NewFailureTracker(freeAttempts int, maxDelay, window time.Duration) *FailureTracker {
+	return &FailureTracker{
+		FreeAttempts: freeAttempts,
+		MaxDelay:     maxDelay,
+		Window:       window,
+		MaxKeys:      maxTrackedKeys,
+		entries:      make(map[string]*failureEntry),
+	}
+}
+
+// RetryAfter reports how long the key is still blocked for.
+func (t *FailureTracker) RetryAfter(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(entry.blockedUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (t *FailureTracker) Fail(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := t.entries[key]
+	if !ok || now.Sub(entry.last) > t.Window {
+		if !ok && len(t.entries) >= t.MaxKeys {
+			t.prune(now)
+			if len(t.entries) >= t.MaxKeys {
+				t.evictOldest()
+			}
+		}
+		entry = &failureEntry{}
+		t.entries[key] = entry
+	}
+
+	entry.count++
+	entry.last = now
+
+	excess := entry.count - t.FreeAttempts
+	if excess <= 0 {
+		return 0
+	}
+
+	delay := t.MaxDelay
+	if excess < 32 {
+		if d := time.Second << (excess - 1); d < delay {
+			delay = d
+		}
+	}
+	entry.blockedUntil = now.Add(delay)
+	return delay
+}
+
+func (t *FailureTracker) Reset(key string) {
+	t.mu.Lock()
+	delete(t.entries, key)
+	t.mu.Unlock()
+}
+
+// prune drops entries outside the window. Callers must hold the lock.
+func (t *FailureTracker) prune(now time.Time) {
+	for key, entry := range t.entries {
+		if now.Sub(entry.last) > t.Window && now.After(entry.blockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+// evictOldest drops the least recently failed key. Callers must hold the lock.
+func (t *FailureTracker) evictOldest() {
+	var oldestKey string
+	var oldest *failureEntry
+	for key, entry := range t.entries {
+		if oldest == nil || entry.last.Before(oldest.last) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	delete(t.entries, oldestKey)
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureTrackerBackoff(t *testing.T) {
+	tracker := NewFailureTracker(2, 5*time.Second, time.Minute)
+
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := tracker.Fail("1.2.3.4"); got != w {
+			t.Errorf("failure %d blocked for %v, want %v", i+1, got, w)
+		}
+	}
+
+	if wait := tracker.RetryAfter("1.2.3.4"); wait <= 0 || wait > 5*time.Second {
+		t.Errorf("RetryAfter() = %v, want within (0, 5s]", wait)
+	}
+	if wait := tracker.RetryAfter("5.6.7.8"); wait != 0 {
+		t.Errorf("RetryAfter() for an unseen key = %v, want 0", wait)
+	}
+
+	tracker.Reset("1.2.3.4")
+	if wait := tracker.RetryAfter("1.2.3.4"); wait != 0 {
+		t.Errorf("RetryAfter() after Reset = %v, want 0", wait)
+	}
+	if got := tracker.Fail("1.2.3.4"); got != 0 {
+		t.Errorf("first failure after Reset blocked for %v, want 0", got)
+	}
+}
+
+func TestFailureTrackerWindow(t *testing.T) {
+	tracker := NewFailureTracker(1, time.Minute, time.Minute)
+	tracker.Fail("a")
+
+	// Age the failure past the window; it should be forgotten.
+	tracker.entries["a"].last = time.Now().Add(-2 * time.Minute)
+	if got := tracker.Fail("a"); got != 0 {
+		t.Errorf("failure after the window blocked for %v, want 0", got)
+	}
+}
+
+func TestFailureTrackerMaxKeys(t *testing.T) {
+	tracker := NewFailureTracker(1, time.Minute, time.Hour)
+	tracker.MaxKeys = 3
+
+	for i, key := range []string{"a", "b", "c"} {
+		tracker.Fail(key)
+		tracker.entries[key].last = time.Now().Add(time.Duration(i-3) * time.Minute)
+	}
+	tracker.Fail("b")
+
+	// All keys are inside the window, so a new one pushes out "a", which
+	// failed least recently.
+	tracker.Fail("d")
+	if len(tracker.entries) != 3 {
+		t.Fatalf("tracking %d keys, want 3", len(tracker.entries))
+	}
+	if _, ok := tracker.entries["a"]; ok {
+		t.Error(`"a" was not evicted`)
+	}
+	if wait := tracker.RetryAfter("b"); wait <= 0 {
+		t.Errorf(`RetryAfter("b") = %v, want it to stay blocked`, wait)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		allowed, remaining, reset := limiter.Allow("key", 3)
+		if !allowed || remaining != 2-i {
+			t.Errorf("request %d: allowed=%v remaining=%d, want true %d", i+1, allowed, remaining, 2-i)
+		}
+		if reset <= 0 || reset > time.Minute {
+			t.Errorf("request %d: reset = %v, want within (0, 1m]", i+1, reset)
+		}
+	}
+
+	if allowed, remaining, _ := limiter.Allow("key", 3); allowed || remaining != 0 {
+		t.Errorf("request over the limit: allowed=%v remaining=%d, want false 0", allowed, remaining)
+	}
+	if allowed, _, _ := limiter.Allow("other", 3); !allowed {
+		t.Error("limit on one key affected another")
+	}
+
+	limiter.windows["key"].start = time.Now().Add(-time.Minute)
+	if allowed, _, _ := limiter.Allow("key", 3); !allowed {
+		t.Error("request in a new window was rejected")
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed from RFC 6238 appendix B,
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; authenticator apps show the last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := hotp(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("hotp at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+
+		counter, ok := ValidateTOTP(rfc6238Secret, tt.want, time.Unix(tt.unix, 0))
+		if !ok || counter != tt.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s) at %d = %d, %v, want %d, true", tt.want, tt.unix, counter, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// "050471" is the code for 1111111111, which falls in step 37037037.
+	at := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		want   bool
+	}{
+		{"current step", rfc6238Secret, "050471", at, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", at, true},
+		{"spaces in code", rfc6238Secret, " 050 471 ", at, true},
+		{"one step late", rfc6238Secret, "050471", at.Add(totpPeriod * time.Second), true},
+		{"one step early", rfc6238Secret, "050471", at.Add(-totpPeriod * time.Second), true},
+		{"two steps late", rfc6238Secret, "050471", at.Add(2 * totpPeriod * time.Second), false},
+		{"wrong code", rfc6238Secret, "050472", at, false},
+		{"too short", rfc6238Secret, "50471", at, false},
+		{"invalid secret", "not base32!", "050471", at, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, tt.at); ok != tt.want {
+				t.Errorf("ValidateTOTP() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes, %v; want 20", secret, len(key), err)
+	}
+
+	uri := TOTPProvisioningURI("Stock Dashboard", "a@example.com", secret)
+	want := "otpauth://totp/Stock%20Dashboard:a@example.com?algorithm=SHA1&digits=6&issuer=Stock+Dashboard&period=30&secret=" + secret
+	if uri != want {
+		t.Errorf("TOTPProvisioningURI() = %q, want %q", uri, want)
+	}
+}